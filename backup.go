@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/voylento/gator/internal/backup"
+	"github.com/voylento/gator/internal/database"
+)
+
+// Writes users, feeds, follows and posts to a JSON file, or to stdout
+// when the file is "-"
+func handleBackup(s *State, cmd Command) error {
+	b, err := backup.Export(context.Background(), s.db)
+	if err != nil {
+		return dbError("%w", err)
+	}
+
+	path := cmd.args[0]
+	if path == "-" {
+		return b.Write(os.Stdout)
+	}
+	if err := writeBackup(path, b, cmd.BoolFlag("force")); err != nil {
+		return err
+	}
+
+	fmt.Printf("Backed up %s to %v\n", backupSummary(b), path)
+	return nil
+}
+
+// Loads a backup into an empty database, or with --replace deletes the
+// current data first. Everything happens in one transaction.
+func handleRestore(s *State, cmd Command) error {
+	path := cmd.args[0]
+	b, err := readBackup(path)
+	if err != nil {
+		return err
+	}
+
+	replace := cmd.BoolFlag("replace")
+	ctx := context.Background()
+	err = s.inTx(ctx, func(q database.Querier) error {
+		if replace {
+			if err := q.DeleteAllUsers(ctx); err != nil {
+				return dbError("Error deleting users: %w", err)
+			}
+		} else {
+			users, err := q.GetUsers(ctx)
+			if err != nil {
+				return dbError("Error getting users: %w", err)
+			}
+			if len(users) > 0 {
+				return newDomainError(errNotEmpty, nil, "The database already has %d user(s). Use --replace to delete everything and restore %v.", len(users), path)
+			}
+		}
+
+		if err := b.Restore(ctx, q); err != nil {
+			return dbError("%w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Restored %s from %v (taken %v)\n", backupSummary(b), path, b.CreatedAt.Local().Format("2006-01-02 15:04"))
+	return nil
+}
+
+// Writes the backup to path. The file is only readable by the owner and
+// an existing file is only overwritten with force.
+func writeBackup(path string, b *backup.Backup, force bool) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+
+	file, err := os.OpenFile(path, flags, 0600)
+	if os.IsExist(err) {
+		return newDomainError(errFileExists, err, "%v already exists. Use --force to overwrite it.", path)
+	}
+	if err != nil {
+		return fmt.Errorf("Error creating backup file: %v", err)
+	}
+
+	if err := b.Write(file); err != nil {
+		file.Close()
+		os.Remove(path)
+		return fmt.Errorf("Error writing backup file: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(path)
+		return fmt.Errorf("Error writing backup file: %v", err)
+	}
+	return nil
+}
+
+func readBackup(path string) (*backup.Backup, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("Error opening backup file: %v", err)
+		}
+		defer file.Close()
+		r = file
+	}
+
+	b, err := backup.Read(r)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	return b, nil
+}
+
+// Reports whether f is an interactive terminal rather than a pipe or file
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func backupSummary(b *backup.Backup) string {
+	return fmt.Sprintf("%d user(s), %d feed(s), %d follow(s) and %d post(s)", len(b.Users), len(b.Feeds), len(b.FeedFollows), len(b.Posts))
+}
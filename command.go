@@ -0,0 +1,250 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Command struct {
+	name  string
+	args  []string
+	flags map[string]string
+}
+
+// Returns the value of a flag declared in the command's Usage, or the
+// flag's default when it was not given on the command line
+func (c Command) Flag(name string) string {
+	return c.flags[name]
+}
+
+func (c Command) BoolFlag(name string) bool {
+	value, _ := strconv.ParseBool(c.flags[name])
+	return value
+}
+
+// A positional argument accepted by a command
+type Arg struct {
+	Name     string
+	Optional bool
+	Repeated bool // only valid on the last argument
+
+	// Candidates offered by shell completion, either a fixed list or
+	// looked up when completing (e.g. feed urls from the database)
+	Values   []string
+	Complete func(*State) ([]string, error)
+}
+
+// A flag accepted by a command. Flags without a Value placeholder are
+// boolean switches, e.g. --dry-run
+type Flag struct {
+	Name    string
+	Value   string
+	Default string
+	Help    string
+}
+
+// Describes how a command is invoked. Run validates the command line
+// against it and the help text is generated from it.
+type Usage struct {
+	Args    []Arg
+	Flags   []Flag
+	Summary string
+	Hidden  bool // left out of the help listing
+
+	// Commands that must run against an outdated or empty database,
+	// e.g. migrate itself
+	SkipSchemaCheck bool
+
+	// Commands that run before gator is configured, or never touch the
+	// database. They get an empty config and no database connection.
+	SkipConfig bool
+}
+
+var errHelpRequested = errors.New("help requested")
+
+type CommandInfo struct {
+	handler func(*State, Command) error
+	usage   Usage
+}
+
+type CommandMap struct {
+	commands map[string]CommandInfo
+}
+
+// Runs a command. Handlers reach the map that runs them (e.g. for help and
+// completion) through s.commands.
+func (c *CommandMap) Run(s *State, cmd Command) error {
+	cmdInfo, ok := c.commands[cmd.name]
+	if !ok {
+		return usageError("Unknown command: %s", cmd.name)
+	}
+
+	args, flags, err := cmdInfo.usage.parse(cmd.args)
+	if errors.Is(err, errHelpRequested) {
+		fmt.Println(cmdInfo.usage.help(cmd.name))
+		return nil
+	}
+	if err != nil {
+		return usageError("%w\nUsage: %s", err, cmdInfo.usage.synopsis(cmd.name))
+	}
+
+	cmd.args = args
+	cmd.flags = flags
+	s.commands = c
+
+	return cmdInfo.handler(s, cmd)
+}
+
+func (c *CommandMap) Register(name string, f func(*State, Command) error, usage Usage) {
+	c.commands[name] = CommandInfo{
+		handler: f,
+		usage:   usage,
+	}
+}
+
+// Returns the detailed help for a command: its synopsis, summary and flags
+func (c *CommandMap) GetHelp(commandName string) (string, bool) {
+	cmdInfo, ok := c.commands[commandName]
+	if !ok {
+		return "", false
+	}
+	return cmdInfo.usage.help(commandName), true
+}
+
+// Returns the one line help of every registered command keyed by name
+func (c *CommandMap) GetAllCommands() map[string]string {
+	result := make(map[string]string)
+	for name, cmdInfo := range c.commands {
+		if cmdInfo.usage.Hidden {
+			continue
+		}
+		result[name] = cmdInfo.usage.line(name)
+	}
+	return result
+}
+
+// e.g. "browse [--json] [limit]"
+func (u Usage) synopsis(name string) string {
+	parts := []string{name}
+	for _, f := range u.Flags {
+		if f.Value == "" {
+			parts = append(parts, fmt.Sprintf("[--%s]", f.Name))
+		} else {
+			parts = append(parts, fmt.Sprintf("[--%s <%s>]", f.Name, f.Value))
+		}
+	}
+	for _, a := range u.Args {
+		arg := a.Name
+		if a.Repeated {
+			arg += "..."
+		}
+		if a.Optional {
+			parts = append(parts, "["+arg+"]")
+		} else {
+			parts = append(parts, "<"+arg+">")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func (u Usage) line(name string) string {
+	return fmt.Sprintf("%s - %s", u.synopsis(name), u.Summary)
+}
+
+func (u Usage) help(name string) string {
+	var b strings.Builder
+	b.WriteString(u.line(name))
+	b.WriteString("\n\nFlags:")
+	for _, f := range u.Flags {
+		flag := "--" + f.Name
+		if f.Value != "" {
+			flag += " <" + f.Value + ">"
+		}
+		fmt.Fprintf(&b, "\n  %-24s %s", flag, f.Help)
+		if f.Default != "" {
+			fmt.Fprintf(&b, " (default: %s)", f.Default)
+		}
+	}
+	fmt.Fprintf(&b, "\n  %-24s %s", "--help, -h", "Show this help")
+	return b.String()
+}
+
+func (u Usage) lookupFlag(name string) (Flag, bool) {
+	for _, f := range u.Flags {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Flag{}, false
+}
+
+// Splits the raw arguments into positional arguments and flag values and
+// checks them against the usage. Flags may be mixed with positional
+// arguments; everything after "--" is treated as positional.
+func (u Usage) parse(raw []string) ([]string, map[string]string, error) {
+	flags := make(map[string]string)
+	for _, f := range u.Flags {
+		if f.Default != "" {
+			flags[f.Name] = f.Default
+		}
+	}
+
+	var args []string
+	for i := 0; i < len(raw); i++ {
+		arg := raw[i]
+		if arg == "--" {
+			args = append(args, raw[i+1:]...)
+			break
+		}
+		if arg == "-h" || arg == "--help" {
+			return nil, nil, errHelpRequested
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			args = append(args, arg)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		flag, ok := u.lookupFlag(name)
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown flag: --%s", name)
+		}
+
+		if flag.Value == "" {
+			if !hasValue {
+				value = "true"
+			} else if _, err := strconv.ParseBool(value); err != nil {
+				return nil, nil, fmt.Errorf("invalid value %q for flag --%s", value, name)
+			}
+		} else if !hasValue {
+			if i+1 >= len(raw) {
+				return nil, nil, fmt.Errorf("flag --%s requires a value", name)
+			}
+			i++
+			value = raw[i]
+		}
+		flags[name] = value
+	}
+
+	var required []string
+	maxArgs := len(u.Args)
+	for _, a := range u.Args {
+		if !a.Optional {
+			required = append(required, a.Name)
+		}
+		if a.Repeated {
+			maxArgs = -1
+		}
+	}
+
+	if len(args) < len(required) {
+		return nil, nil, fmt.Errorf("missing argument <%s>", required[len(args)])
+	}
+	if maxArgs >= 0 && len(args) > maxArgs {
+		return nil, nil, fmt.Errorf("too many arguments")
+	}
+
+	return args, flags, nil
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/voylento/gator/internal/config"
+	"github.com/voylento/gator/internal/database"
+	"github.com/voylento/gator/internal/storage"
+)
+
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// The generated scripts hand the words typed so far to the hidden
+// __complete command, so candidates always match the registered commands
+// and can include values looked up in the database.
+const bashCompletion = `# bash completion for gator
+# Install: source <(gator completion bash)
+_gator() {
+    local line="${COMP_LINE:0:COMP_POINT}"
+    local -a words
+    read -ra words <<< "$line"
+    if [[ "$line" =~ [[:space:]]$ ]]; then
+        words+=("")
+    fi
+    local cur="${words[${#words[@]}-1]}"
+
+    local IFS=$'\n'
+    COMPREPLY=($(gator __complete -- "${words[@]:1}" 2>/dev/null))
+
+    # bash splits urls on ':', only replace the part after the last colon
+    if [[ "$cur" == *:* && "$COMP_WORDBREAKS" == *:* ]]; then
+        local prefix="${cur%"${cur##*:}"}"
+        COMPREPLY=("${COMPREPLY[@]#"$prefix"}")
+    fi
+}
+complete -o default -F _gator gator
+`
+
+const zshCompletion = `#compdef gator
+# zsh completion for gator
+# Install: source <(gator completion zsh)
+_gator() {
+    local -a candidates
+    candidates=(${(f)"$(gator __complete -- "${(@)words[2,CURRENT]}" 2>/dev/null)"})
+    compadd -Q -a candidates
+}
+
+if [ "$funcstack[1]" = "_gator" ]; then
+    _gator "$@"
+else
+    compdef _gator gator
+fi
+`
+
+const fishCompletion = `# fish completion for gator
+# Install: gator completion fish > ~/.config/fish/completions/gator.fish
+function __gator_complete
+    set -l tokens (commandline -opc) (commandline -ct)
+    gator __complete -- $tokens[2..-1] 2>/dev/null
+end
+
+complete -c gator -f -a '(__gator_complete)'
+`
+
+func handleCompletion(s *State, cmd Command) error {
+	switch cmd.args[0] {
+	case "bash":
+		fmt.Print(bashCompletion)
+	case "zsh":
+		fmt.Print(zshCompletion)
+	case "fish":
+		fmt.Print(fishCompletion)
+	default:
+		return usageError("unsupported shell %q (expected one of %s)", cmd.args[0], strings.Join(completionShells, ", "))
+	}
+
+	return nil
+}
+
+// Prints the candidates for the last word, one per line. The words are the
+// command line typed so far without the program name; the last one is the
+// (possibly empty) word being completed. __complete runs without loading
+// the config so that commands and flags complete before 'gator init'.
+func handleComplete(s *State, cmd Command) error {
+	if s.db == nil && s.config.FileExists() {
+		// profile names; a broken file only loses those candidates
+		s.config.ReadFile(s.configOptions.Profile)
+	}
+	defer func() {
+		if s.conn != nil {
+			s.conn.Close()
+		}
+	}()
+
+	words := stripGlobalOptions(cmd.args)
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	current := words[len(words)-1]
+
+	var candidates []string
+	if len(words) == 1 {
+		candidates = commandNames(s.commands)
+	} else {
+		candidates = s.commands.completeArgs(s, words[0], words[1:len(words)-1], current)
+	}
+
+	for _, c := range candidates {
+		if strings.HasPrefix(c, current) {
+			fmt.Println(c)
+		}
+	}
+
+	return nil
+}
+
+func (c *CommandMap) completeArgs(s *State, name string, previous []string, current string) []string {
+	cmdInfo, ok := c.commands[name]
+	if !ok {
+		return nil
+	}
+	usage := cmdInfo.usage
+
+	if strings.HasPrefix(current, "-") {
+		candidates := []string{"--help"}
+		for _, f := range usage.Flags {
+			candidates = append(candidates, "--"+f.Name)
+		}
+		return candidates
+	}
+
+	// Work out which positional argument is being completed, skipping
+	// flags and the values that belong to them
+	position := 0
+	for i := 0; i < len(previous); i++ {
+		word := previous[i]
+		if len(word) < 2 || word[0] != '-' {
+			position++
+			continue
+		}
+		flag, ok := usage.lookupFlag(strings.TrimLeft(word, "-"))
+		if ok && flag.Value != "" {
+			i++
+		}
+	}
+
+	if len(usage.Args) == 0 {
+		return nil
+	}
+	if position >= len(usage.Args) {
+		if !usage.Args[len(usage.Args)-1].Repeated {
+			return nil
+		}
+		position = len(usage.Args) - 1
+	}
+
+	arg := usage.Args[position]
+	if arg.Complete == nil {
+		return arg.Values
+	}
+
+	values, err := arg.Complete(s)
+	if err != nil {
+		// Completion should never print errors into the user's prompt
+		return arg.Values
+	}
+	return values
+}
+
+// Removes global options such as --output=json so they do not count as
+// the command or its arguments
+func stripGlobalOptions(words []string) []string {
+	var rest []string
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		switch {
+		case strings.HasPrefix(word, "--output="), strings.HasPrefix(word, "--config="), strings.HasPrefix(word, "--profile="):
+		case (word == "--output" || word == "-o" || word == "--config" || word == "--profile") && i < len(words)-1:
+			i++
+		default:
+			rest = append(rest, word)
+		}
+	}
+	return rest
+}
+
+func commandNames(c *CommandMap) []string {
+	var names []string
+	for name := range c.GetAllCommands() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func completeCommandNames(s *State) ([]string, error) {
+	return commandNames(s.commands), nil
+}
+
+// Returns the database for completing values such as feed urls, connecting
+// on first use. Fails when gator has not been set up yet.
+func completionDB(s *State) (database.Querier, error) {
+	if s.db != nil {
+		return s.db, nil
+	}
+
+	cfg, err := config.LoadConfig(s.configOptions)
+	if err != nil {
+		return nil, err
+	}
+	db, err := storage.Open(cfg.DbUrl)
+	if err != nil {
+		return nil, err
+	}
+	s.config = cfg
+	s.conn = db
+	s.db = db.Queries()
+	return s.db, nil
+}
+
+func completeUserNames(s *State) ([]string, error) {
+	db, err := completionDB(s)
+	if err != nil {
+		return nil, err
+	}
+	users, err := db.GetUsers(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(users))
+	for _, user := range users {
+		names = append(names, user.Name)
+	}
+	return names, nil
+}
+
+func completeFeedUrls(s *State) ([]string, error) {
+	db, err := completionDB(s)
+	if err != nil {
+		return nil, err
+	}
+	feeds, err := db.GetAllFeeds(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	urls := make([]string, 0, len(feeds))
+	for _, feed := range feeds {
+		urls = append(urls, feed.Url)
+	}
+	return urls, nil
+}
+
+// Only the feeds the current user follows can be unfollowed
+func completeFollowedFeedUrls(s *State) ([]string, error) {
+	db, err := completionDB(s)
+	if err != nil {
+		return nil, err
+	}
+	user, err := db.GetUser(context.Background(), s.config.UserName)
+	if err != nil {
+		return nil, err
+	}
+
+	follows, err := db.GetFollowsByUser(context.Background(), user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	urls := make([]string, 0, len(follows))
+	for _, follow := range follows {
+		urls = append(urls, follow.FeedUrl)
+	}
+	return urls, nil
+}
+
+func completeProfileNames(s *State) ([]string, error) {
+	return s.config.ProfileNames(), nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/voylento/gator/internal/config"
+)
+
+// Before 'gator init' there is no config file: commands and flags still
+// complete, values from the database are left out
+func TestCompletionWithoutConfig(t *testing.T) {
+	opts := config.Options{Path: filepath.Join(t.TempDir(), "missing.json")}
+	t.Setenv("GATOR_DB_URL", "")
+	cfg, err := config.NewConfig(opts)
+	if err != nil {
+		t.Fatalf("NewConfig: %v", err)
+	}
+	s := &State{config: cfg, configOptions: opts}
+	cmds := newCommandMap()
+
+	if !cmds.commands["__complete"].usage.SkipConfig {
+		t.Error("__complete loads the config")
+	}
+
+	flags := cmds.completeArgs(s, "init", nil, "--")
+	for _, want := range []string{"--db-url", "--user", "--force"} {
+		if !slices.Contains(flags, want) {
+			t.Errorf("init flags %q do not include %v", flags, want)
+		}
+	}
+
+	if got := cmds.completeArgs(s, "login", nil, ""); len(got) != 0 {
+		t.Errorf("login candidates = %q without a database, want none", got)
+	}
+	if s.db != nil {
+		t.Error("completion connected to a database without a config")
+	}
+}
+
+// help and __complete look up commands in the map that runs them
+func TestHelpAndCompleteUseRunningCommandMap(t *testing.T) {
+	s := newTestState(t, "memdb", &fakeFetcher{})
+
+	mustRun(t, s, "help")
+	mustRun(t, s, "help", "follow")
+	if code := exitCode(runCommand(s, "help", "no-such-command")); code != exitUsage {
+		t.Errorf("help of an unknown command exit code = %d, want %d", code, exitUsage)
+	}
+
+	mustRun(t, s, "__complete", "--", "he")
+	mustRun(t, s, "__complete", "--", "help", "fo")
+}
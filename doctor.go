@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/voylento/gator/internal/config"
+	"github.com/voylento/gator/internal/migrations"
+	"github.com/voylento/gator/internal/output"
+	"github.com/voylento/gator/internal/storage"
+)
+
+const (
+	checkPass = "pass"
+	checkFail = "fail"
+	checkSkip = "skip"
+)
+
+type checkResult struct {
+	name   string
+	status string
+	detail string
+}
+
+// Runs each setup check in order and prints a pass/fail report. Checks
+// that depend on an earlier failed check are skipped rather than failing
+// with a confusing error of their own.
+func handleDoctor(s *State, cmd Command) error {
+	ctx := context.Background()
+	var results []checkResult
+	report := func(name, status, detail string) {
+		results = append(results, checkResult{name: name, status: status, detail: detail})
+	}
+
+	cfg, err := config.LoadConfig(s.configOptions)
+	if err != nil {
+		report("config", checkFail, err.Error())
+	} else {
+		report("config", checkPass, fmt.Sprintf("%s (profile %s)", cfg.Path(), cfg.Profile))
+	}
+
+	var db *storage.DB
+	if cfg == nil {
+		report("database", checkSkip, "no config")
+	} else if db, err = pingDatabase(ctx, cfg.DbUrl); err != nil {
+		report("database", checkFail, err.Error())
+		db = nil
+	} else {
+		defer db.Close()
+		report("database", checkPass, config.MaskDbUrl(cfg.DbUrl))
+	}
+
+	if db == nil {
+		report("migrations", checkSkip, "database not reachable")
+		report("current user", checkSkip, "database not reachable")
+		report("gen_random_uuid", checkSkip, "database not reachable")
+	} else {
+		checkMigrations(ctx, db, report)
+		checkCurrentUser(ctx, db, cfg.UserName, report)
+
+		if db.Backend != storage.Postgres {
+			report("gen_random_uuid", checkSkip, "only needed by postgres")
+		} else if _, err := db.ExecContext(ctx, "SELECT gen_random_uuid()"); err != nil {
+			report("gen_random_uuid", checkFail, fmt.Sprintf("%v (requires postgres 13+ or the pgcrypto extension)", err))
+		} else {
+			report("gen_random_uuid", checkPass, "available")
+		}
+	}
+
+	if cmd.BoolFlag("fetch") {
+		if db == nil {
+			report("feeds", checkSkip, "database not reachable")
+		} else {
+			checkFeeds(ctx, db, cfg.Fetch, report)
+		}
+	}
+
+	failed := 0
+	table := output.Table{
+		Columns: []string{"check", "status", "detail"},
+	}
+	for _, result := range results {
+		if result.status == checkFail {
+			failed++
+		}
+		table.Append(result.name, result.status, result.detail)
+	}
+
+	err = s.printRecords(table, func() {
+		for _, result := range results {
+			fmt.Printf("[%s] %-16s %s\n", result.status, result.name, result.detail)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d check(s) failed", failed)
+	}
+	return nil
+}
+
+func pingDatabase(ctx context.Context, dbUrl string) (*storage.DB, error) {
+	db, err := storage.Open(dbUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := db.PingContext(pingCtx); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func checkMigrations(ctx context.Context, db *storage.DB, report func(string, string, string)) {
+	migrator, err := migrations.New(db)
+	if err != nil {
+		report("migrations", checkFail, err.Error())
+		return
+	}
+
+	current, latest, err := migrator.Versions(ctx)
+	switch {
+	case err != nil:
+		report("migrations", checkFail, err.Error())
+	case current < latest:
+		report("migrations", checkFail, fmt.Sprintf("schema version %d, gator requires %d (run 'gator migrate up')", current, latest))
+	default:
+		report("migrations", checkPass, fmt.Sprintf("schema version %d", current))
+	}
+}
+
+func checkCurrentUser(ctx context.Context, db *storage.DB, userName string, report func(string, string, string)) {
+	if userName == "" {
+		report("current user", checkFail, "no user configured (run 'gator register <name>')")
+		return
+	}
+
+	_, err := db.Queries().GetUser(ctx, userName)
+	if errors.Is(err, sql.ErrNoRows) {
+		report("current user", checkFail, fmt.Sprintf("user %v does not exist (run 'gator login <name>' or 'gator register %v')", userName, userName))
+		return
+	}
+	if err != nil {
+		report("current user", checkFail, fmt.Sprintf("%v: %v", userName, err))
+		return
+	}
+	report("current user", checkPass, userName)
+}
+
+func checkFeeds(ctx context.Context, db *storage.DB, settings config.FetchSettings, report func(string, string, string)) {
+	fetcher, err := newFetcher(settings)
+	if err != nil {
+		report("feeds", checkFail, err.Error())
+		return
+	}
+
+	feeds, err := db.Queries().GetAllFeeds(ctx)
+	if err != nil {
+		report("feeds", checkFail, err.Error())
+		return
+	}
+	if len(feeds) == 0 {
+		report("feeds", checkSkip, "no feeds added")
+		return
+	}
+
+	for _, feed := range feeds {
+		name := "feed " + feed.Name
+		rssFeed, err := fetcher.FetchFeed(feed.Url)
+		if err != nil {
+			report(name, checkFail, fmt.Sprintf("%s: %v", feed.Url, err))
+			continue
+		}
+		report(name, checkPass, fmt.Sprintf("%s: %d item(s)", feed.Url, len(rssFeed.Channel.Item)))
+	}
+}
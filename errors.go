@@ -0,0 +1,133 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Exit codes, so that scripts can tell failures apart
+const (
+	exitOK       = 0
+	exitFailure  = 1 // any other error
+	exitUsage    = 2 // bad command line
+	exitNotFound = 3 // a user, feed or post that does not exist
+	exitDatabase = 4 // the database could not be reached or a query failed
+	exitNetwork  = 5 // a feed or web page could not be fetched
+	exitConflict = 6 // a user, feed, follow or file that already exists, data in the way of a restore, or a feed owned by someone else
+)
+
+// Domain errors. Handlers translate database errors (unique violations,
+// sql.ErrNoRows) into these so that the user sees what went wrong and
+// what to do about it instead of a driver message.
+var (
+	errUserExists       = errors.New("user already exists")
+	errUserNotFound     = errors.New("user not found")
+	errNotLoggedIn      = errors.New("not logged in")
+	errFeedExists       = errors.New("feed already exists")
+	errFeedNotFound     = errors.New("feed not found")
+	errAlreadyFollowing = errors.New("already following feed")
+	errNotFollowing     = errors.New("not following feed")
+	errNotEmpty         = errors.New("database is not empty")
+	errNotFeedOwner     = errors.New("not the feed owner")
+	errFileExists       = errors.New("file already exists")
+)
+
+var domainExitCodes = map[error]int{
+	errUserExists:       exitConflict,
+	errUserNotFound:     exitNotFound,
+	errNotLoggedIn:      exitNotFound,
+	errFeedExists:       exitConflict,
+	errFeedNotFound:     exitNotFound,
+	errAlreadyFollowing: exitConflict,
+	errNotFollowing:     exitNotFound,
+	errNotEmpty:         exitConflict,
+	errNotFeedOwner:     exitConflict,
+	errFileExists:       exitConflict,
+}
+
+// A domain error with an actionable message. It matches its kind and the
+// underlying database error (if any) with errors.Is.
+type domainError struct {
+	kind  error
+	msg   string
+	cause error
+}
+
+func (e *domainError) Error() string {
+	return e.msg
+}
+
+func (e *domainError) Unwrap() []error {
+	if e.cause == nil {
+		return []error{e.kind}
+	}
+	return []error{e.kind, e.cause}
+}
+
+func newDomainError(kind error, cause error, format string, args ...any) error {
+	return &domainError{kind: kind, msg: fmt.Sprintf(format, args...), cause: cause}
+}
+
+// An error that ends gator with a particular exit code. Handlers return
+// these (usually through the helpers below) and main turns them into the
+// exit code in exitCode; nothing else calls os.Exit.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// The helpers format like fmt.Errorf, so %w keeps the cause available to
+// errors.Is and errors.As
+
+func usageError(format string, args ...any) error {
+	return &exitError{code: exitUsage, err: fmt.Errorf(format, args...)}
+}
+
+func notFoundError(format string, args ...any) error {
+	return &exitError{code: exitNotFound, err: fmt.Errorf(format, args...)}
+}
+
+// A query that found no row is reported as not found rather than as a
+// database failure
+func dbError(format string, args ...any) error {
+	err := fmt.Errorf(format, args...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &exitError{code: exitNotFound, err: err}
+	}
+	return &exitError{code: exitDatabase, err: err}
+}
+
+func networkError(format string, args ...any) error {
+	return &exitError{code: exitNetwork, err: fmt.Errorf(format, args...)}
+}
+
+// Decides the exit code for an error returned by a command. The
+// outermost exitError wins; an unclassified sql.ErrNoRows means something
+// was not found.
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+
+	var exitErr *exitError
+	if errors.As(err, &exitErr) {
+		return exitErr.code
+	}
+	var domainErr *domainError
+	if errors.As(err, &domainErr) {
+		return domainExitCodes[domainErr.kind]
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return exitNotFound
+	}
+	return exitFailure
+}
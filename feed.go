@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/voylento/gator/internal/database"
+	"github.com/voylento/gator/internal/storage"
+)
+
+// Renames, moves or deletes a feed. Only the user who added the feed
+// (feeds.user_id) may change it since the change affects every follower.
+func handleFeed(s *State, cmd Command, user database.User) error {
+	action := cmd.args[0]
+	url := cmd.args[1]
+
+	switch action {
+	case "rename":
+		if len(cmd.args) != 3 {
+			return usageError("Usage: feed rename <url> <new_name>")
+		}
+	case "seturl":
+		if len(cmd.args) != 3 {
+			return usageError("Usage: feed seturl <url> <new_url>")
+		}
+	case "delete":
+		if len(cmd.args) != 2 {
+			return usageError("Usage: feed delete <url>")
+		}
+	default:
+		return usageError("unknown feed action %q (expected rename, seturl or delete)", action)
+	}
+
+	ctx := context.Background()
+	feed, err := getFeed(s, url)
+	if err != nil {
+		return err
+	}
+
+	if feed.UserID != user.ID {
+		return newDomainError(errNotFeedOwner, nil, "Only the user who added feed %v can change it", feed.Url)
+	}
+
+	switch action {
+	case "rename":
+		updated, err := s.db.UpdateFeedName(ctx, database.UpdateFeedNameParams{
+			ID:   feed.ID,
+			Name: cmd.args[2],
+		})
+		if err != nil {
+			return dbError("Error renaming feed: %w", err)
+		}
+		fmt.Printf("Renamed feed %v from %v to %v\n", updated.Url, feed.Name, updated.Name)
+	case "seturl":
+		updated, err := s.db.UpdateFeedUrl(ctx, database.UpdateFeedUrlParams{
+			ID:  feed.ID,
+			Url: cmd.args[2],
+		})
+		if storage.IsUniqueViolation(err) {
+			return newDomainError(errFeedExists, err, "Another feed already uses %v. Run 'gator follow %v' to follow that feed instead.", cmd.args[2], cmd.args[2])
+		}
+		if err != nil {
+			return dbError("Error changing feed url: %w", err)
+		}
+		fmt.Printf("Changed url of feed %v from %v to %v\n", updated.Name, feed.Url, updated.Url)
+		if err := s.config.RenameRetentionFeed(feed.Url, updated.Url); err != nil {
+			fmt.Printf("Warning: could not move the retention limits of %v to %v: %v\n", feed.Url, updated.Url, err)
+		}
+	case "delete":
+		if err := s.db.DeleteFeed(ctx, feed.ID); err != nil {
+			return dbError("Error deleting feed: %w", err)
+		}
+		// follows and posts are removed by the ON DELETE CASCADE constraints
+		fmt.Printf("Deleted feed %v (%v) with its follows and posts\n", feed.Name, feed.Url)
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/voylento/gator/internal/database"
+	"github.com/voylento/gator/internal/migrations"
+	"github.com/voylento/gator/internal/storage"
+)
+
+const defaultDbUrl = "postgres://postgres:@localhost:5432/gator?sslmode=disable"
+
+// Walks a new user through the manual setup steps: checks the database
+// connection, applies the migrations, creates the first user and writes
+// the config file.
+func handleInit(s *State, cmd Command) error {
+	if s.config.FileExists() {
+		force := cmd.BoolFlag("force")
+
+		// only the selected profile is replaced, other profiles and the
+		// fetch and retention settings are kept. A file that cannot be
+		// read is replaced with --force, which is how it gets repaired.
+		if err := s.config.ReadFile(s.configOptions.Profile); err != nil {
+			if !force {
+				return newDomainError(errFileExists, err, "%v\nUse --force to replace it with a new config file.", err)
+			}
+			fmt.Printf("Warning: %v\nThe config file will be replaced, other profiles and settings in it are lost.\n", err)
+		} else if !force {
+			return newDomainError(errFileExists, nil, "Config file %s already exists. Use --force to replace the %s profile.", s.config.Path(), s.config.Profile)
+		}
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+
+	dbUrl := cmd.Flag("db-url")
+	if dbUrl == "" {
+		var err error
+		dbUrl, err = prompt(reader, "Database URL", defaultDbUrl)
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx := context.Background()
+	db, err := storage.Open(dbUrl)
+	if err != nil {
+		return usageError("Invalid database URL: %w", err)
+	}
+	defer db.Close()
+
+	pingCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := db.PingContext(pingCtx); err != nil {
+		if db.Backend == storage.SQLite {
+			return dbError("Could not open %s: %w\nCheck that the directory exists and is writable", dbUrl, err)
+		}
+		return dbError("Could not connect to %s: %w\nCheck that postgres is running and the database exists (CREATE DATABASE gator;)", dbUrl, err)
+	}
+	fmt.Println("Connected to database")
+
+	migrator, err := migrations.New(db)
+	if err != nil {
+		return err
+	}
+	versions, err := migrator.Up(ctx)
+	if err != nil {
+		return dbError("%w", err)
+	}
+	fmt.Printf("Applied %d migration(s)\n", len(versions))
+
+	userName := cmd.Flag("user")
+	if userName == "" {
+		userName, err = prompt(reader, "User name", os.Getenv("USER"))
+		if err != nil {
+			return err
+		}
+	}
+	if userName == "" {
+		return usageError("A user name is required")
+	}
+
+	queries := db.Queries()
+	user, err := queries.GetUser(ctx, userName)
+	if errors.Is(err, sql.ErrNoRows) {
+		timeNow := time.Now()
+		user, err = queries.CreateUser(ctx, database.CreateUserParams{
+			ID:        uuid.New(),
+			CreatedAt: timeNow,
+			UpdatedAt: timeNow,
+			Name:      userName,
+		})
+		if err != nil {
+			return dbError("Error creating user %v: %w", userName, err)
+		}
+		fmt.Printf("Created user %v\n", user.Name)
+	} else if err != nil {
+		return dbError("Error reading user %v: %w", userName, err)
+	} else {
+		fmt.Printf("Using existing user %v\n", user.Name)
+	}
+
+	s.config.DbUrl = dbUrl
+	s.config.UserName = user.Name
+	if err := s.config.Save(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote config to %s\n", s.config.Path())
+	fmt.Println("Setup complete. Try 'gator addfeed <name> <url>' next.")
+
+	return nil
+}
+
+// Asks for a value on stdin, returning def when the answer is empty
+func prompt(reader *bufio.Reader, label string, def string) (string, error) {
+	if def != "" {
+		fmt.Printf("%s [%s]: ", label, def)
+	} else {
+		fmt.Printf("%s: ", label)
+	}
+
+	line, err := reader.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("Error reading input: %v", err)
+	}
+
+	answer := strings.TrimSpace(line)
+	if answer == "" {
+		return def, nil
+	}
+	return answer, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/voylento/gator/internal/config"
+)
+
+func TestInitForceReplacesCorruptConfig(t *testing.T) {
+	dir := t.TempDir()
+	opts := config.Options{Path: filepath.Join(dir, "config.json")}
+	if err := os.WriteFile(opts.Path, []byte(`{"db_url": "sqlite:`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	dbUrl := "sqlite:" + filepath.Join(dir, "gator.db")
+
+	newState := func() *State {
+		cfg, err := config.NewConfig(opts)
+		if err != nil {
+			t.Fatalf("NewConfig: %v", err)
+		}
+		return &State{config: cfg, configOptions: opts}
+	}
+
+	err := runCommand(newState(), "init", "--db-url", dbUrl, "--user", "dan")
+	if err == nil {
+		t.Fatal("init over a corrupt config file without --force succeeded")
+	}
+	if code := exitCode(err); code != exitConflict {
+		t.Errorf("init over a corrupt config file exit code = %d, want %d", code, exitConflict)
+	}
+
+	mustRun(t, newState(), "init", "--force", "--db-url", dbUrl, "--user", "dan")
+
+	cfg, err := config.LoadConfig(opts)
+	if err != nil {
+		t.Fatalf("LoadConfig after init --force: %v", err)
+	}
+	if cfg.DbUrl != dbUrl || cfg.UserName != "dan" {
+		t.Errorf("config = %v / %v, want %v / dan", cfg.DbUrl, cfg.UserName, dbUrl)
+	}
+}
+
+func TestInitRefusesExistingConfig(t *testing.T) {
+	dir := t.TempDir()
+	opts := config.Options{Path: filepath.Join(dir, "config.json")}
+	dbUrl := "sqlite:" + filepath.Join(dir, "gator.db")
+	contents := fmt.Sprintf(`{"db_url": %q, "user_name": "dan"}`, dbUrl)
+	if err := os.WriteFile(opts.Path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.NewConfig(opts)
+	if err != nil {
+		t.Fatalf("NewConfig: %v", err)
+	}
+
+	err = runCommand(&State{config: cfg, configOptions: opts}, "init", "--db-url", dbUrl, "--user", "eve")
+	if code := exitCode(err); code != exitConflict {
+		t.Errorf("init over an existing config exit code = %d (%v), want %d", code, err, exitConflict)
+	}
+}
@@ -0,0 +1,236 @@
+// Package backup exports the users, feeds, follows and posts of a gator
+// database to a versioned JSON document and restores them, with either
+// storage backend.
+package backup
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/voylento/gator/internal/database"
+)
+
+// The format version written by this gator. Backups with a newer version
+// are rejected since they may hold data this version would drop. Version 2
+// added starred posts.
+const Version = 2
+
+type Backup struct {
+	Version     int          `json:"version"`
+	CreatedAt   time.Time    `json:"created_at"`
+	Users       []User       `json:"users"`
+	Feeds       []Feed       `json:"feeds"`
+	FeedFollows []FeedFollow `json:"feed_follows"`
+	Posts       []Post       `json:"posts"`
+}
+
+type User struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Name      string    `json:"name"`
+}
+
+type Feed struct {
+	ID            uuid.UUID  `json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	Name          string     `json:"name"`
+	Url           string     `json:"url"`
+	UserID        uuid.UUID  `json:"user_id"`
+	SiteUrl       string     `json:"site_url,omitempty"`
+	Description   string     `json:"description,omitempty"`
+	LastFetchedAt *time.Time `json:"last_fetched_at,omitempty"`
+	RedirectUrl   string     `json:"redirect_url,omitempty"`
+	RedirectCount int32      `json:"redirect_count,omitempty"`
+	RetiredAt     *time.Time `json:"retired_at,omitempty"`
+}
+
+type FeedFollow struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    uuid.UUID `json:"user_id"`
+	FeedID    uuid.UUID `json:"feed_id"`
+}
+
+type Post struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Title       string    `json:"title"`
+	Url         string    `json:"url"`
+	Description string    `json:"description"`
+	PublishedAt time.Time `json:"published_at"`
+	FeedID      uuid.UUID `json:"feed_id"`
+	Starred     bool      `json:"starred,omitempty"`
+}
+
+// Reads everything from the database. Follows and posts of feeds or users
+// created while the export runs (e.g. by agg) are left out, so the backup
+// can always be restored.
+func Export(ctx context.Context, q database.Querier) (*Backup, error) {
+	b := &Backup{
+		Version:     Version,
+		CreatedAt:   time.Now().UTC(),
+		Users:       []User{},
+		Feeds:       []Feed{},
+		FeedFollows: []FeedFollow{},
+		Posts:       []Post{},
+	}
+
+	users, err := q.GetUsers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading users: %w", err)
+	}
+	userIDs := make(map[uuid.UUID]bool)
+	for _, user := range users {
+		userIDs[user.ID] = true
+		b.Users = append(b.Users, User{
+			ID:        user.ID,
+			CreatedAt: user.CreatedAt,
+			UpdatedAt: user.UpdatedAt,
+			Name:      user.Name,
+		})
+	}
+
+	feeds, err := q.GetAllFeeds(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading feeds: %w", err)
+	}
+	feedIDs := make(map[uuid.UUID]bool)
+	for _, feed := range feeds {
+		if !userIDs[feed.UserID] {
+			continue
+		}
+		feedIDs[feed.ID] = true
+		b.Feeds = append(b.Feeds, Feed{
+			ID:            feed.ID,
+			CreatedAt:     feed.CreatedAt,
+			UpdatedAt:     feed.UpdatedAt,
+			Name:          feed.Name,
+			Url:           feed.Url,
+			UserID:        feed.UserID,
+			SiteUrl:       feed.SiteUrl,
+			Description:   feed.Description,
+			LastFetchedAt: timePtr(feed.LastFetchedAt),
+			RedirectUrl:   feed.RedirectUrl.String,
+			RedirectCount: feed.RedirectCount,
+			RetiredAt:     timePtr(feed.RetiredAt),
+		})
+	}
+
+	follows, err := q.GetAllFeedFollows(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading feed follows: %w", err)
+	}
+	for _, follow := range follows {
+		if !userIDs[follow.UserID] || !feedIDs[follow.FeedID] {
+			continue
+		}
+		b.FeedFollows = append(b.FeedFollows, FeedFollow(follow))
+	}
+
+	posts, err := q.GetAllPosts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading posts: %w", err)
+	}
+	for _, post := range posts {
+		if !feedIDs[post.FeedID] {
+			continue
+		}
+		b.Posts = append(b.Posts, Post(post))
+	}
+
+	return b, nil
+}
+
+// Writes the backup as indented JSON
+func (b *Backup) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", " ")
+	return encoder.Encode(b)
+}
+
+// Reads a backup written by Write, by this or an older version of gator
+func Read(r io.Reader) (*Backup, error) {
+	var b Backup
+	if err := json.NewDecoder(r).Decode(&b); err != nil {
+		return nil, fmt.Errorf("Error decoding backup: %v", err)
+	}
+
+	switch {
+	case b.Version <= 0:
+		return nil, fmt.Errorf("Not a gator backup (no version)")
+	case b.Version > Version:
+		return nil, fmt.Errorf("Backup format version %d was written by a newer gator, this one reads up to version %d", b.Version, Version)
+	}
+	return &b, nil
+}
+
+// Inserts the backup's rows with their original ids and timestamps. The
+// database should be empty; run it in a transaction so that a conflict
+// does not leave a partial restore behind.
+func (b *Backup) Restore(ctx context.Context, q database.Querier) error {
+	for _, user := range b.Users {
+		_, err := q.CreateUser(ctx, database.CreateUserParams(user))
+		if err != nil {
+			return fmt.Errorf("Error restoring user %v: %w", user.Name, err)
+		}
+	}
+
+	for _, feed := range b.Feeds {
+		err := q.RestoreFeed(ctx, database.RestoreFeedParams{
+			ID:            feed.ID,
+			CreatedAt:     feed.CreatedAt,
+			UpdatedAt:     feed.UpdatedAt,
+			Name:          feed.Name,
+			Url:           feed.Url,
+			UserID:        feed.UserID,
+			LastFetchedAt: nullTime(feed.LastFetchedAt),
+			RedirectUrl:   sql.NullString{String: feed.RedirectUrl, Valid: feed.RedirectUrl != ""},
+			RedirectCount: feed.RedirectCount,
+			RetiredAt:     nullTime(feed.RetiredAt),
+			SiteUrl:       feed.SiteUrl,
+			Description:   feed.Description,
+		})
+		if err != nil {
+			return fmt.Errorf("Error restoring feed %v: %w", feed.Url, err)
+		}
+	}
+
+	for _, follow := range b.FeedFollows {
+		_, err := q.CreateFeedFollow(ctx, database.CreateFeedFollowParams(follow))
+		if err != nil {
+			return fmt.Errorf("Error restoring feed follow %v: %w", follow.ID, err)
+		}
+	}
+
+	for _, post := range b.Posts {
+		err := q.RestorePost(ctx, database.RestorePostParams(post))
+		if err != nil {
+			return fmt.Errorf("Error restoring post %v: %w", post.Url, err)
+		}
+	}
+
+	return nil
+}
+
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
+}
@@ -2,61 +2,481 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 )
 const (
 	configFileName = ".gatorconfig.json"
+	defaultProfile = "default"
+
+	defaultRedirectsBeforeMove = 3
+	defaultTimeout             = Duration(10 * time.Second)
+	defaultUserAgent           = "gator"
+	defaultMaxResponseBytes    = 10 << 20
+	defaultMaxAttempts         = 3
+	defaultRetryDelay          = Duration(time.Second)
+	defaultMaxRetryDelay       = Duration(time.Minute)
+	defaultHostInterval        = Duration(500 * time.Millisecond)
+	defaultPruneInterval       = Duration(time.Hour)
+
+	envConfig  = "GATOR_CONFIG"
+	envProfile = "GATOR_PROFILE"
+	envDbUrl   = "GATOR_DB_URL"
+	envUser    = "GATOR_USER"
 )
 
+// Options select the config file and profile, usually from the global
+// --config and --profile flags. Empty values use the defaults.
+type Options struct {
+	Path		string
+	Profile	string
+}
+
 type Config struct{
 	DbUrl				string	`json:"db_url"`
 	UserName		string	`json:"user_name"`
+	Profile			string
+	Fetch				FetchSettings
+	Retention		RetentionSettings
+
+	// where the config file lives and why that location was chosen
+	path				string
+	pathSource	string
+
+	// values as stored in the file, before environment overrides
+	stored			fileValues
+	sources			map[string]string
+}
+
+// A named database and current user, e.g. a local dev database and the
+// shared team database
+type Profile struct {
+	DbUrl				string	`json:"db_url"`
+	UserName		string	`json:"user_name"`
+}
+
+// Settings for fetching feeds, shared by all profiles
+type FetchSettings struct {
+	// Number of consecutive fetches permanently redirected to the same
+	// url before the feed's url is updated
+	RedirectsBeforeMove	int	`json:"redirects_before_move,omitempty"`
+
+	// Time allowed for a whole request, including reading the body
+	Timeout							Duration	`json:"timeout,omitempty"`
+	UserAgent						string		`json:"user_agent,omitempty"`
+
+	// Proxy url for all requests; when empty the HTTP_PROXY and
+	// HTTPS_PROXY environment variables are used
+	Proxy								string		`json:"proxy,omitempty"`
+
+	// Largest feed accepted, in bytes after decompression
+	MaxResponseBytes		int64			`json:"max_response_bytes,omitempty"`
+
+	// Tries per request when a publisher times out or answers 429/5xx;
+	// 1 disables retries
+	MaxAttempts					int				`json:"max_attempts,omitempty"`
+
+	// Wait before the first retry, doubled for each further retry up to
+	// MaxRetryDelay (with jitter). A Retry-After header replaces the wait.
+	RetryDelay					Duration	`json:"retry_delay,omitempty"`
+	MaxRetryDelay				Duration	`json:"max_retry_delay,omitempty"`
+
+	// Minimum time between two requests to the same host
+	HostInterval				Duration	`json:"host_interval,omitempty"`
+}
+
+// Limits on the posts kept in the database, shared by all profiles. Zero
+// values keep posts forever.
+type RetentionSettings struct {
+	// Posts published longer ago than this are pruned
+	MaxAge							Duration	`json:"max_age,omitempty"`
+
+	// Only the newest posts of each feed are kept
+	MaxPostsPerFeed			int				`json:"max_posts_per_feed,omitempty"`
+
+	// How often agg prunes posts
+	PruneInterval				Duration	`json:"prune_interval,omitempty"`
+
+	// Limits for single feeds, keyed by feed url. Zero values fall back
+	// to the limits above.
+	Feeds								map[string]FeedRetention	`json:"feeds,omitempty"`
+}
+
+// Limits for a single feed
+type FeedRetention struct {
+	MaxAge				Duration	`json:"max_age,omitempty"`
+	MaxPosts			int				`json:"max_posts,omitempty"`
+}
+
+// Returns the limits that apply to the feed with the given url
+func (r RetentionSettings) For(feedUrl string) FeedRetention {
+	limits := FeedRetention{
+		MaxAge:			r.MaxAge,
+		MaxPosts:		r.MaxPostsPerFeed,
+	}
+
+	feed := r.Feeds[feedUrl]
+	if feed.MaxAge > 0 {
+		limits.MaxAge = feed.MaxAge
+	}
+	if feed.MaxPosts > 0 {
+		limits.MaxPosts = feed.MaxPosts
+	}
+	return limits
 }
 
+// Returns the urls in Feeds that are not in feedUrls, sorted
+func (r RetentionSettings) UnknownFeeds(feedUrls []string) []string {
+	var unknown []string
+	for url := range r.Feeds {
+		if !slices.Contains(feedUrls, url) {
+			unknown = append(unknown, url)
+		}
+	}
+	slices.Sort(unknown)
+	return unknown
+}
+
+// Reports whether any limit is set, i.e. whether there is anything to prune
+func (r RetentionSettings) Enabled() bool {
+	if r.MaxAge > 0 || r.MaxPostsPerFeed > 0 {
+		return true
+	}
+	for _, feed := range r.Feeds {
+		if feed.MaxAge > 0 || feed.MaxPosts > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// A time.Duration written as a string such as "30s" in the config file
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a string such as \"30s\"")
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// The on-disk format. The top level db_url and user_name mirror the current
+// profile so that config files stay readable by older versions of gator;
+// a file without profiles is treated as a single "default" profile.
+type fileValues struct {
+	DbUrl						string							`json:"db_url"`
+	UserName				string							`json:"user_name"`
+	CurrentProfile	string							`json:"current_profile,omitempty"`
+	Profiles				map[string]Profile	`json:"profiles,omitempty"`
+	Fetch						FetchSettings				`json:"fetch,omitempty"`
+	Retention				RetentionSettings		`json:"retention,omitempty"`
+}
+
+// Creates an empty config bound to the file it would be saved to
+func NewConfig(opts Options) (*Config, error) {
+	path, source, err := resolvePath(opts.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{
+		Profile:		defaultProfile,
+		path:				path,
+		pathSource:	source,
+		sources:		make(map[string]string),
+	}
+	config.selectProfile(opts.Profile)
+	config.Fetch.applyDefaults()
+	config.Retention.applyDefaults()
+
+	return config, nil
+}
+
+// Loads the config in layers: the selected profile from the config file
+// (see resolvePath) and then the GATOR_DB_URL and GATOR_USER environment
+// variables. The file may be missing when the database url comes from the
+// environment.
+func LoadConfig(opts Options) (*Config, error) {
+	config, err := NewConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	err = config.ReadFile(opts.Profile)
+	switch {
+	case err == nil:
+		if _, ok := config.stored.Profiles[config.Profile]; !ok {
+			return nil, fmt.Errorf("Profile %q not found in %s", config.Profile, config.path)
+		}
+	case errors.Is(err, os.ErrNotExist) && os.Getenv(envDbUrl) != "":
+		// configured entirely from the environment
+	default:
+		return nil, err
+	}
+
+	if dbUrl := os.Getenv(envDbUrl); dbUrl != "" {
+		config.DbUrl = dbUrl
+		config.sources["db_url"] = "env " + envDbUrl
+	}
+	if user := os.Getenv(envUser); user != "" {
+		config.UserName = user
+		config.sources["user_name"] = "env " + envUser
+	}
+
+	if config.DbUrl == "" {
+		return nil, fmt.Errorf("No database url configured in %s or %s", config.path, envDbUrl)
+	}
+
+	config.Fetch.applyDefaults()
+	config.Retention.applyDefaults()
+
+	return config, nil
+}
 
-func LoadConfig() (*Config, error) {
-	file, err := os.Open(getConfigFilePath())
+// Reads the profiles and shared settings from the config file and selects
+// the profile, without applying environment variables or requiring a
+// database url. init uses it so that rewriting one profile keeps the rest
+// of the file.
+func (c *Config) ReadFile(flagProfile string) error {
+	file, err := os.Open(c.path)
 	if err != nil {
-		return nil, fmt.Errorf("Error reading config file: %v\n", err)
+		return fmt.Errorf("Error reading config file: %w", err)
 	}
 	defer file.Close()
 
-	var config Config
-	
+	var stored fileValues
 	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&config); err != nil {
-		return nil, fmt.Errorf("Error decoding config file: %v", err)
+	if err := decoder.Decode(&stored); err != nil {
+		return fmt.Errorf("Error decoding config file %s: %v", c.path, err)
 	}
+	stored.normalize()
+	c.stored = stored
+	c.selectProfile(flagProfile)
 
-	return &config, nil
+	if profile, ok := c.stored.Profiles[c.Profile]; ok {
+		c.DbUrl = profile.DbUrl
+		c.UserName = profile.UserName
+		c.sources["db_url"] = "file"
+		c.sources["user_name"] = "file"
+	}
+	c.Fetch = c.stored.Fetch
+	c.Retention = c.stored.Retention
+	c.Fetch.applyDefaults()
+	c.Retention.applyDefaults()
+
+	return nil
+}
+
+// Picks the active profile: the --profile flag, then $GATOR_PROFILE, then
+// the current profile saved in the file
+func (c *Config) selectProfile(flagProfile string) {
+	switch {
+	case flagProfile != "":
+		c.Profile = flagProfile
+		c.sources["profile"] = "flag --profile"
+	case os.Getenv(envProfile) != "":
+		c.Profile = os.Getenv(envProfile)
+		c.sources["profile"] = "env " + envProfile
+	case c.stored.CurrentProfile != "":
+		c.Profile = c.stored.CurrentProfile
+		c.sources["profile"] = "file"
+	default:
+		c.Profile = defaultProfile
+		c.sources["profile"] = "default"
+	}
 }
 
-func (c *Config) SetUser(user string) {
+func (c *Config) SetUser(user string) error {
 	c.UserName = user
-	file, err := os.Create(getConfigFilePath())
-	if err != nil {
-		fmt.Printf("Error opening config file: %v\n", err)
-		panic(err)
+	c.sources["user_name"] = "file"
+	return c.Save()
+}
+
+// Moves the retention limits of a feed to its new url and saves the
+// config, so that they still apply after feed seturl or a permanent
+// redirect. Nothing is written when the feed has no limits of its own.
+func (c *Config) RenameRetentionFeed(oldUrl, newUrl string) error {
+	limits, ok := c.stored.Retention.Feeds[oldUrl]
+	if !ok || oldUrl == newUrl {
+		return nil
 	}
-	defer file.Close()
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", " ")
+	values := c.stored
+	values.Retention.Feeds = maps.Clone(values.Retention.Feeds)
+	delete(values.Retention.Feeds, oldUrl)
+	values.Retention.Feeds[newUrl] = limits
+	if err := c.write(values); err != nil {
+		return err
+	}
+
+	c.Retention.Feeds = maps.Clone(values.Retention.Feeds)
+	return nil
+}
+
+// Writes the config to the config file, creating it if needed. The active
+// profile is updated with the current values, except for values that came
+// from environment variables. The old file is only replaced once the new
+// one is fully written.
+func (c *Config) Save() error {
+	values := c.stored
+	values.normalize()
+	values.Profiles = maps.Clone(values.Profiles)
 
-	err = encoder.Encode(c)
+	profile := values.Profiles[c.Profile]
+	if !c.fromEnv("db_url") {
+		profile.DbUrl = c.DbUrl
+	}
+	if !c.fromEnv("user_name") {
+		profile.UserName = c.UserName
+	}
+	values.Profiles[c.Profile] = profile
+	if values.CurrentProfile == "" {
+		values.CurrentProfile = c.Profile
+	}
+
+	return c.write(values)
+}
+
+func (c *Config) write(values fileValues) error {
+	current := values.Profiles[values.CurrentProfile]
+	values.DbUrl = current.DbUrl
+	values.UserName = current.UserName
+
+	data, err := json.MarshalIndent(values, "", " ")
 	if err != nil {
-		fmt.Printf("Error encoding config: %v", err)
-		panic(err)
+		return fmt.Errorf("Error encoding config: %v", err)
+	}
+
+	if err := writeFileAtomic(c.path, append(data, '\n')); err != nil {
+		return fmt.Errorf("Error writing config file: %v", err)
+	}
+
+	c.stored = values
+	return nil
+}
+
+func (f *FetchSettings) applyDefaults() {
+	if f.RedirectsBeforeMove <= 0 {
+		f.RedirectsBeforeMove = defaultRedirectsBeforeMove
+	}
+	if f.Timeout <= 0 {
+		f.Timeout = defaultTimeout
+	}
+	if f.UserAgent == "" {
+		f.UserAgent = defaultUserAgent
+	}
+	if f.MaxResponseBytes <= 0 {
+		f.MaxResponseBytes = defaultMaxResponseBytes
+	}
+	if f.MaxAttempts <= 0 {
+		f.MaxAttempts = defaultMaxAttempts
+	}
+	if f.RetryDelay <= 0 {
+		f.RetryDelay = defaultRetryDelay
+	}
+	if f.MaxRetryDelay <= 0 {
+		f.MaxRetryDelay = defaultMaxRetryDelay
+	}
+	if f.HostInterval <= 0 {
+		f.HostInterval = defaultHostInterval
+	}
+}
+
+func (r *RetentionSettings) applyDefaults() {
+	if r.PruneInterval <= 0 {
+		r.PruneInterval = defaultPruneInterval
+	}
+}
+
+// Converts a file written before profiles existed into a single
+// default profile
+func (v *fileValues) normalize() {
+	if len(v.Profiles) == 0 {
+		profiles := make(map[string]Profile)
+		if v.DbUrl != "" || v.UserName != "" {
+			profiles[defaultProfile] = Profile{DbUrl: v.DbUrl, UserName: v.UserName}
+			v.CurrentProfile = defaultProfile
+		}
+		v.Profiles = profiles
 	}
 }
 
-func getConfigFilePath() string {
-	homeDir, err := os.UserHomeDir()
+// Returns the location of the config file
+func (c *Config) Path() string {
+	return c.path
+}
+
+// Reports whether the config file has already been written
+func (c *Config) FileExists() bool {
+	_, err := os.Stat(c.path)
+	return err == nil
+}
+
+func (c *Config) fromEnv(key string) bool {
+	return strings.HasPrefix(c.sources[key], "env ")
+}
+
+// Writes data to a temp file in the same directory and renames it over
+// path, so a crash or full disk never leaves a truncated config behind.
+// The file is only readable by the owner since it holds the database url.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
 	if err != nil {
-		fmt.Printf("Error getting user home directory: %v", err)
-		panic(err)
+		return err
+	}
+	tmpName := tmp.Name()
+
+	// CreateTemp already uses 0600, chmod in case the umask changed it
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+
+	if err := os.Rename(tmpName, path); err != nil {
+		os.Remove(tmpName)
+		return err
 	}
 
-	return homeDir + "/" + configFileName
+	return nil
 }
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRenameRetentionFeed(t *testing.T) {
+	opts := Options{Path: filepath.Join(t.TempDir(), "config.json")}
+	contents := `{
+ "db_url": "sqlite:gator.db",
+ "user_name": "dan",
+ "retention": {
+  "max_posts_per_feed": 50,
+  "feeds": {
+   "https://old.example.com/feed.xml": {"max_posts": 10},
+   "https://other.example.com/feed.xml": {"max_age": "24h"}
+  }
+ }
+}`
+	if err := os.WriteFile(opts.Path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(opts)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+
+	const oldUrl, newUrl = "https://old.example.com/feed.xml", "https://new.example.com/feed.xml"
+	if err := config.RenameRetentionFeed(oldUrl, newUrl); err != nil {
+		t.Fatalf("RenameRetentionFeed: %v", err)
+	}
+	if got := config.Retention.For(newUrl).MaxPosts; got != 10 {
+		t.Errorf("max posts of the new url = %d, want 10", got)
+	}
+
+	reloaded, err := LoadConfig(opts)
+	if err != nil {
+		t.Fatalf("LoadConfig after rename: %v", err)
+	}
+	if got := reloaded.Retention.For(newUrl).MaxPosts; got != 10 {
+		t.Errorf("saved max posts of the new url = %d, want 10", got)
+	}
+	if got := reloaded.Retention.For(oldUrl).MaxPosts; got != 50 {
+		t.Errorf("saved max posts of the old url = %d, want the global 50", got)
+	}
+	if got := reloaded.Retention.For("https://other.example.com/feed.xml").MaxAge; got != Duration(24*time.Hour) {
+		t.Errorf("other feed max age = %v, want 24h", got)
+	}
+	if reloaded.UserName != "dan" {
+		t.Errorf("user name = %q after rename, want dan", reloaded.UserName)
+	}
+
+	// a feed without limits of its own leaves the file alone
+	before, err := os.ReadFile(opts.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reloaded.RenameRetentionFeed("https://plain.example.com/feed.xml", oldUrl); err != nil {
+		t.Fatalf("RenameRetentionFeed without limits: %v", err)
+	}
+	after, err := os.ReadFile(opts.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(before) != string(after) {
+		t.Error("renaming a feed without limits rewrote the config file")
+	}
+}
+
+func TestRetentionUnknownFeeds(t *testing.T) {
+	r := RetentionSettings{Feeds: map[string]FeedRetention{
+		"https://b.example.com/feed.xml":     {MaxPosts: 1},
+		"https://a.example.com/feed.xml":     {MaxPosts: 1},
+		"https://known.example.com/feed.xml": {MaxPosts: 1},
+	}}
+
+	got := r.UnknownFeeds([]string{"https://known.example.com/feed.xml", "https://unlimited.example.com/feed.xml"})
+	want := []string{"https://a.example.com/feed.xml", "https://b.example.com/feed.xml"}
+	if !slices.Equal(got, want) {
+		t.Errorf("UnknownFeeds = %q, want %q", got, want)
+	}
+}
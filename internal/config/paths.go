@@ -0,0 +1,48 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Picks the config file in order of precedence: the --config flag,
+// $GATOR_CONFIG, $XDG_CONFIG_HOME/gator/config.json when it exists and
+// finally ~/.gatorconfig.json. Returns the path and where it came from.
+func resolvePath(explicitPath string) (string, string, error) {
+	if explicitPath != "" {
+		return explicitPath, "flag --config", nil
+	}
+
+	if path := os.Getenv(envConfig); path != "" {
+		return path, "env " + envConfig, nil
+	}
+
+	if path, ok := xdgConfigPath(); ok {
+		if _, err := os.Stat(path); err == nil {
+			return path, "xdg", nil
+		}
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", "", fmt.Errorf("Error getting user home directory: %v", err)
+	}
+
+	return filepath.Join(homeDir, configFileName), "default", nil
+}
+
+// $XDG_CONFIG_HOME/gator/config.json, falling back to ~/.config as the
+// XDG base directory spec describes
+func xdgConfigPath() (string, bool) {
+	base := os.Getenv("XDG_CONFIG_HOME")
+	if base == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", false
+		}
+		base = filepath.Join(homeDir, ".config")
+	}
+
+	return filepath.Join(base, "gator", "config.json"), true
+}
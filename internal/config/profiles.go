@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// Returns the names of the profiles saved in the config file, sorted
+func (c *Config) ProfileNames() []string {
+	return slices.Sorted(maps.Keys(c.stored.Profiles))
+}
+
+// Returns a saved profile by name
+func (c *Config) GetProfile(name string) (Profile, bool) {
+	profile, ok := c.stored.Profiles[name]
+	return profile, ok
+}
+
+// Returns the profile used when no --profile flag is given
+func (c *Config) CurrentProfile() string {
+	return c.stored.CurrentProfile
+}
+
+// Makes name the profile used by default and saves the config
+func (c *Config) UseProfile(name string) error {
+	if _, ok := c.stored.Profiles[name]; !ok {
+		return fmt.Errorf("Profile %q does not exist", name)
+	}
+
+	values := c.stored
+	values.CurrentProfile = name
+	return c.write(values)
+}
+
+func (c *Config) AddProfile(name string, profile Profile) error {
+	if name == "" {
+		return fmt.Errorf("Profile name must not be empty")
+	}
+	if _, ok := c.stored.Profiles[name]; ok {
+		return fmt.Errorf("Profile %q already exists", name)
+	}
+
+	values := c.stored
+	values.normalize()
+	values.Profiles = maps.Clone(values.Profiles)
+	values.Profiles[name] = profile
+	if values.CurrentProfile == "" {
+		values.CurrentProfile = name
+	}
+	return c.write(values)
+}
+
+// Deletes a profile. The current profile cannot be removed; switch to
+// another one first.
+func (c *Config) RemoveProfile(name string) error {
+	if _, ok := c.stored.Profiles[name]; !ok {
+		return fmt.Errorf("Profile %q does not exist", name)
+	}
+	if name == c.stored.CurrentProfile {
+		return fmt.Errorf("Profile %q is the current profile, switch to another profile before removing it", name)
+	}
+
+	values := c.stored
+	values.Profiles = maps.Clone(values.Profiles)
+	delete(values.Profiles, name)
+	return c.write(values)
+}
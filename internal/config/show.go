@@ -0,0 +1,109 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+)
+
+// A single effective config value and where it came from
+type Setting struct {
+	Name   string
+	Value  string
+	Source string
+}
+
+// Returns the effective settings with the database password masked
+func (c *Config) Settings() []Setting {
+	settings := []Setting{
+		{Name: "config_file", Value: c.path, Source: c.pathSource},
+		{Name: "profile", Value: c.Profile, Source: c.source("profile")},
+		{Name: "db_url", Value: MaskDbUrl(c.DbUrl), Source: c.source("db_url")},
+		{Name: "user_name", Value: c.UserName, Source: c.source("user_name")},
+		{Name: "fetch.redirects_before_move", Value: strconv.Itoa(c.Fetch.RedirectsBeforeMove), Source: c.fileOrDefault(c.stored.Fetch.RedirectsBeforeMove != 0)},
+		{Name: "fetch.timeout", Value: c.Fetch.Timeout.String(), Source: c.fileOrDefault(c.stored.Fetch.Timeout != 0)},
+		{Name: "fetch.user_agent", Value: c.Fetch.UserAgent, Source: c.fileOrDefault(c.stored.Fetch.UserAgent != "")},
+		{Name: "fetch.proxy", Value: MaskDbUrl(c.Fetch.Proxy), Source: c.proxySource()},
+		{Name: "fetch.max_response_bytes", Value: strconv.FormatInt(c.Fetch.MaxResponseBytes, 10), Source: c.fileOrDefault(c.stored.Fetch.MaxResponseBytes != 0)},
+		{Name: "fetch.max_attempts", Value: strconv.Itoa(c.Fetch.MaxAttempts), Source: c.fileOrDefault(c.stored.Fetch.MaxAttempts != 0)},
+		{Name: "fetch.retry_delay", Value: c.Fetch.RetryDelay.String(), Source: c.fileOrDefault(c.stored.Fetch.RetryDelay != 0)},
+		{Name: "fetch.max_retry_delay", Value: c.Fetch.MaxRetryDelay.String(), Source: c.fileOrDefault(c.stored.Fetch.MaxRetryDelay != 0)},
+		{Name: "fetch.host_interval", Value: c.Fetch.HostInterval.String(), Source: c.fileOrDefault(c.stored.Fetch.HostInterval != 0)},
+		{Name: "retention.max_age", Value: maxAge(c.Retention.MaxAge), Source: c.fileOrDefault(c.stored.Retention.MaxAge != 0)},
+		{Name: "retention.max_posts_per_feed", Value: maxPosts(c.Retention.MaxPostsPerFeed), Source: c.fileOrDefault(c.stored.Retention.MaxPostsPerFeed != 0)},
+		{Name: "retention.prune_interval", Value: c.Retention.PruneInterval.String(), Source: c.fileOrDefault(c.stored.Retention.PruneInterval != 0)},
+	}
+
+	urls := slices.Sorted(maps.Keys(c.Retention.Feeds))
+	for _, feedUrl := range urls {
+		feed := c.Retention.Feeds[feedUrl]
+		settings = append(settings, Setting{
+			Name:   "retention.feeds." + feedUrl,
+			Value:  fmt.Sprintf("max_age=%s max_posts=%s", maxAge(feed.MaxAge), maxPosts(feed.MaxPosts)),
+			Source: "file",
+		})
+	}
+	return settings
+}
+
+func maxAge(d Duration) string {
+	if d <= 0 {
+		return "unlimited"
+	}
+	return d.String()
+}
+
+func maxPosts(n int) string {
+	if n <= 0 {
+		return "unlimited"
+	}
+	return strconv.Itoa(n)
+}
+
+// A proxy in the file wins over the proxy environment variables
+func (c *Config) proxySource() string {
+	switch {
+	case c.Fetch.Proxy != "":
+		return "file"
+	case os.Getenv("HTTPS_PROXY") != "" || os.Getenv("https_proxy") != "" ||
+		os.Getenv("HTTP_PROXY") != "" || os.Getenv("http_proxy") != "":
+		return "env HTTP(S)_PROXY"
+	default:
+		return "unset"
+	}
+}
+
+func (c *Config) fileOrDefault(inFile bool) string {
+	if inFile {
+		return "file"
+	}
+	return "default"
+}
+
+func (c *Config) source(key string) string {
+	if source, ok := c.sources[key]; ok {
+		return source
+	}
+	return "unset"
+}
+
+var (
+	dsnPassword   = regexp.MustCompile(`(^|\s)(password\s*=\s*)('(?:[^'\\]|\\.)*'|\S+)`)
+	queryPassword = regexp.MustCompile(`(^|&)(password=)[^&]*`)
+)
+
+// Hides the password in a postgres url, both in the user info and in a
+// password query parameter, or in a key=value connection string
+func MaskDbUrl(dbUrl string) string {
+	u, err := url.Parse(dbUrl)
+	if err == nil && u.Scheme != "" {
+		u.RawQuery = queryPassword.ReplaceAllString(u.RawQuery, "${1}${2}xxxxx")
+		return u.Redacted()
+	}
+
+	return dsnPassword.ReplaceAllString(dbUrl, "${1}${2}xxxxx")
+}
@@ -0,0 +1,629 @@
+// Package memdb is an in-memory implementation of database.Querier, so
+// commands can be exercised without a Postgres server. It mirrors the
+// behaviour of the schema the handlers rely on: unique constraints fail with
+// the same pq errors, deletes cascade, missing rows are sql.ErrNoRows and
+// the queries keep their ordering.
+package memdb
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/voylento/gator/internal/database"
+)
+
+// Postgres error codes returned for constraint violations
+const (
+	foreignKeyViolation = "23503"
+	uniqueViolation     = "23505"
+)
+
+// Rows are kept in insertion order, like a freshly loaded table
+type Store struct {
+	mu      sync.Mutex
+	users   []database.User
+	feeds   []database.Feed
+	follows []database.FeedFollow
+	posts   []database.Post
+}
+
+var _ database.Querier = (*Store)(nil)
+
+func New() *Store {
+	return &Store{}
+}
+
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+func violation(code string, constraint string, table string) error {
+	message := "duplicate key value violates unique constraint"
+	if code == foreignKeyViolation {
+		message = "insert or update violates foreign key constraint"
+	}
+	return &pq.Error{
+		Code:       pq.ErrorCode(code),
+		Message:    fmt.Sprintf("%s %q", message, constraint),
+		Table:      table,
+		Constraint: constraint,
+	}
+}
+
+// A sql.Result for deletes
+type result int64
+
+func (r result) LastInsertId() (int64, error) {
+	return 0, fmt.Errorf("LastInsertId is not supported")
+}
+
+func (r result) RowsAffected() (int64, error) {
+	return int64(r), nil
+}
+
+func (s *Store) userIndex(id uuid.UUID) int {
+	return slices.IndexFunc(s.users, func(u database.User) bool { return u.ID == id })
+}
+
+func (s *Store) feedIndex(id uuid.UUID) int {
+	return slices.IndexFunc(s.feeds, func(f database.Feed) bool { return f.ID == id })
+}
+
+// Removes feeds, follows and posts that reference missing users or feeds,
+// like ON DELETE CASCADE
+func (s *Store) cascade() {
+	s.feeds = slices.DeleteFunc(s.feeds, func(f database.Feed) bool { return s.userIndex(f.UserID) < 0 })
+	s.follows = slices.DeleteFunc(s.follows, func(ff database.FeedFollow) bool {
+		return s.userIndex(ff.UserID) < 0 || s.feedIndex(ff.FeedID) < 0
+	})
+	s.posts = slices.DeleteFunc(s.posts, func(p database.Post) bool { return s.feedIndex(p.FeedID) < 0 })
+}
+
+// Applies update to the feed with the given id and returns the result
+func (s *Store) updateFeed(id uuid.UUID, update func(*database.Feed)) (database.Feed, error) {
+	i := s.feedIndex(id)
+	if i < 0 {
+		return database.Feed{}, sql.ErrNoRows
+	}
+	update(&s.feeds[i])
+	return s.feeds[i], nil
+}
+
+func (s *Store) ClearFeedRedirect(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.updateFeed(id, func(f *database.Feed) {
+		f.RedirectUrl = sql.NullString{}
+		f.RedirectCount = 0
+	})
+	return nil
+}
+
+// Counts the unstarred posts of a feed published before $2 or beyond its
+// newest $3 unstarred posts
+func (s *Store) CountPostsToPrune(ctx context.Context, arg database.CountPostsToPruneParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return int64(len(s.postsToPrune(arg.FeedID, arg.PublishedAt, arg.Limit))), nil
+}
+
+func (s *Store) CreateFeed(ctx context.Context, arg database.CreateFeedParams) (database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if slices.ContainsFunc(s.feeds, func(f database.Feed) bool { return f.Url == arg.Url }) {
+		return database.Feed{}, violation(uniqueViolation, "feeds_url_key", "feeds")
+	}
+	if s.userIndex(arg.UserID) < 0 {
+		return database.Feed{}, violation(foreignKeyViolation, "fk_users", "feeds")
+	}
+
+	feed := database.Feed{
+		ID:          arg.ID,
+		CreatedAt:   arg.CreatedAt,
+		UpdatedAt:   arg.UpdatedAt,
+		Name:        arg.Name,
+		Url:         arg.Url,
+		UserID:      arg.UserID,
+		SiteUrl:     arg.SiteUrl,
+		Description: arg.Description,
+	}
+	s.feeds = append(s.feeds, feed)
+	return feed, nil
+}
+
+func (s *Store) CreateFeedFollow(ctx context.Context, arg database.CreateFeedFollowParams) ([]database.CreateFeedFollowRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if slices.ContainsFunc(s.follows, func(ff database.FeedFollow) bool {
+		return ff.UserID == arg.UserID && ff.FeedID == arg.FeedID
+	}) {
+		return nil, violation(uniqueViolation, "unique_user_feed", "feed_follows")
+	}
+	userIdx := s.userIndex(arg.UserID)
+	if userIdx < 0 {
+		return nil, violation(foreignKeyViolation, "fk_users", "feed_follows")
+	}
+	feedIdx := s.feedIndex(arg.FeedID)
+	if feedIdx < 0 {
+		return nil, violation(foreignKeyViolation, "fk_feeds", "feed_follows")
+	}
+
+	s.follows = append(s.follows, database.FeedFollow{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UpdatedAt: arg.UpdatedAt,
+		UserID:    arg.UserID,
+		FeedID:    arg.FeedID,
+	})
+	return []database.CreateFeedFollowRow{{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UpdatedAt: arg.UpdatedAt,
+		UserID:    arg.UserID,
+		FeedID:    arg.FeedID,
+		FeedName:  s.feeds[feedIdx].Name,
+		UserName:  s.users[userIdx].Name,
+	}}, nil
+}
+
+func (s *Store) CreatePost(ctx context.Context, arg database.CreatePostParams) (database.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if slices.ContainsFunc(s.posts, func(p database.Post) bool { return p.Url == arg.Url }) {
+		return database.Post{}, violation(uniqueViolation, "posts_url_key", "posts")
+	}
+	if s.feedIndex(arg.FeedID) < 0 {
+		return database.Post{}, violation(foreignKeyViolation, "fk_feeds", "posts")
+	}
+
+	created := now()
+	post := database.Post{
+		ID:          uuid.New(),
+		CreatedAt:   created,
+		UpdatedAt:   created,
+		Title:       arg.Title,
+		Url:         arg.Url,
+		Description: arg.Description,
+		PublishedAt: arg.PublishedAt,
+		FeedID:      arg.FeedID,
+	}
+	s.posts = append(s.posts, post)
+	return post, nil
+}
+
+func (s *Store) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if slices.ContainsFunc(s.users, func(u database.User) bool { return u.Name == arg.Name }) {
+		return database.User{}, violation(uniqueViolation, "users_name_key", "users")
+	}
+
+	user := database.User{
+		ID:        arg.ID,
+		CreatedAt: arg.CreatedAt,
+		UpdatedAt: arg.UpdatedAt,
+		Name:      arg.Name,
+	}
+	s.users = append(s.users, user)
+	return user, nil
+}
+
+func (s *Store) DeleteAllFeeds(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.feeds = nil
+	s.cascade()
+	return nil
+}
+
+func (s *Store) DeleteAllUsers(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users = nil
+	s.cascade()
+	return nil
+}
+
+func (s *Store) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.feeds = slices.DeleteFunc(s.feeds, func(f database.Feed) bool { return f.ID == id })
+	s.cascade()
+	return nil
+}
+
+func (s *Store) DeleteFeedFollows(ctx context.Context, arg database.DeleteFeedFollowsParams) (sql.Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before := len(s.follows)
+	s.follows = slices.DeleteFunc(s.follows, func(ff database.FeedFollow) bool {
+		return ff.UserID == arg.UserID && ff.FeedID == arg.FeedID
+	})
+	return result(before - len(s.follows)), nil
+}
+
+func (s *Store) GetAllFeedFollows(ctx context.Context) ([]database.FeedFollow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.follows), nil
+}
+
+func (s *Store) GetAllFeeds(ctx context.Context) ([]database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.feeds), nil
+}
+
+// Posts ordered by feed and publish time
+func (s *Store) GetAllPosts(ctx context.Context) ([]database.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	posts := slices.Clone(s.posts)
+	slices.SortStableFunc(posts, func(a, b database.Post) int {
+		if c := bytes.Compare(a.FeedID[:], b.FeedID[:]); c != 0 {
+			return c
+		}
+		return a.PublishedAt.Compare(b.PublishedAt)
+	})
+	return posts, nil
+}
+
+func (s *Store) GetFeed(ctx context.Context, url string) (database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.feeds, func(f database.Feed) bool { return f.Url == url })
+	if i < 0 {
+		return database.Feed{}, sql.ErrNoRows
+	}
+	return s.feeds[i], nil
+}
+
+func (s *Store) GetFeedsByUser(ctx context.Context, userID uuid.UUID) ([]database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var feeds []database.Feed
+	for _, feed := range s.feeds {
+		if feed.UserID == userID {
+			feeds = append(feeds, feed)
+		}
+	}
+	return feeds, nil
+}
+
+func (s *Store) GetFollowsByUser(ctx context.Context, userID uuid.UUID) ([]database.GetFollowsByUserRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rows []database.GetFollowsByUserRow
+	for _, ff := range s.follows {
+		if ff.UserID != userID {
+			continue
+		}
+		feed := s.feeds[s.feedIndex(ff.FeedID)]
+		user := s.users[s.userIndex(ff.UserID)]
+		rows = append(rows, database.GetFollowsByUserRow{
+			ID:       ff.ID,
+			UserID:   ff.UserID,
+			FeedID:   ff.FeedID,
+			FeedName: feed.Name,
+			FeedUrl:  feed.Url,
+			UserName: user.Name,
+		})
+	}
+	return rows, nil
+}
+
+// Never fetched feeds first, then the longest unfetched, then by id
+func (s *Store) GetNextFeedToFetch(ctx context.Context) (database.GetNextFeedToFetchRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var next *database.Feed
+	for i := range s.feeds {
+		feed := &s.feeds[i]
+		if feed.RetiredAt.Valid {
+			continue
+		}
+		if next == nil || fetchesBefore(feed, next) {
+			next = feed
+		}
+	}
+	if next == nil {
+		return database.GetNextFeedToFetchRow{}, sql.ErrNoRows
+	}
+
+	return database.GetNextFeedToFetchRow{
+		ID:            next.ID,
+		CreatedAt:     next.CreatedAt,
+		UpdatedAt:     next.UpdatedAt,
+		LastFetchedAt: next.LastFetchedAt,
+		Name:          next.Name,
+		Url:           next.Url,
+		UserID:        next.UserID,
+	}, nil
+}
+
+func fetchesBefore(a, b *database.Feed) bool {
+	if a.LastFetchedAt.Valid != b.LastFetchedAt.Valid {
+		return !a.LastFetchedAt.Valid
+	}
+	if a.LastFetchedAt.Valid && !a.LastFetchedAt.Time.Equal(b.LastFetchedAt.Time) {
+		return a.LastFetchedAt.Time.Before(b.LastFetchedAt.Time)
+	}
+	return bytes.Compare(a.ID[:], b.ID[:]) < 0
+}
+
+// Returns the publish time of a feed's unstarred post at offset $2 in
+// prune order
+func (s *Store) GetNthNewestPostDate(ctx context.Context, arg database.GetNthNewestPostDateParams) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	posts := s.unstarredPostsNewestFirst(arg.FeedID)
+	if arg.Offset < 0 || int(arg.Offset) >= len(posts) {
+		return time.Time{}, sql.ErrNoRows
+	}
+	return posts[arg.Offset].PublishedAt, nil
+}
+
+// Posts of the feeds the user follows, newest first
+func (s *Store) GetPostsForUser(ctx context.Context, arg database.GetPostsForUserParams) ([]database.GetPostsForUserRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rows []database.GetPostsForUserRow
+	for _, post := range s.posts {
+		followed := slices.ContainsFunc(s.follows, func(ff database.FeedFollow) bool {
+			return ff.UserID == arg.UserID && ff.FeedID == post.FeedID
+		})
+		if !followed {
+			continue
+		}
+		rows = append(rows, database.GetPostsForUserRow{
+			ID:          post.ID,
+			CreatedAt:   post.CreatedAt,
+			UpdatedAt:   post.UpdatedAt,
+			Title:       post.Title,
+			Url:         post.Url,
+			Description: post.Description,
+			PublishedAt: post.PublishedAt,
+			FeedID:      post.FeedID,
+			Starred:     post.Starred,
+			FeedName:    s.feeds[s.feedIndex(post.FeedID)].Name,
+		})
+	}
+
+	slices.SortStableFunc(rows, func(a, b database.GetPostsForUserRow) int {
+		return b.PublishedAt.Compare(a.PublishedAt)
+	})
+	if int(arg.Limit) < len(rows) {
+		rows = rows[:max(arg.Limit, 0)]
+	}
+	return rows, nil
+}
+
+// Ids of the unstarred posts of a feed published before cutoff or beyond
+// its newest limit unstarred posts
+func (s *Store) postsToPrune(feedID uuid.UUID, cutoff time.Time, limit int32) map[uuid.UUID]bool {
+	prune := make(map[uuid.UUID]bool)
+	for i, post := range s.unstarredPostsNewestFirst(feedID) {
+		if post.PublishedAt.Before(cutoff) || i >= int(limit) {
+			prune[post.ID] = true
+		}
+	}
+	return prune
+}
+
+// The unstarred posts of a feed in the order prune keeps them
+func (s *Store) unstarredPostsNewestFirst(feedID uuid.UUID) []database.Post {
+	var posts []database.Post
+	for _, post := range s.posts {
+		if post.FeedID == feedID && !post.Starred {
+			posts = append(posts, post)
+		}
+	}
+	slices.SortStableFunc(posts, func(a, b database.Post) int {
+		if c := b.PublishedAt.Compare(a.PublishedAt); c != 0 {
+			return c
+		}
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return posts
+}
+
+func (s *Store) GetUser(ctx context.Context, name string) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.users, func(u database.User) bool { return u.Name == name })
+	if i < 0 {
+		return database.User{}, sql.ErrNoRows
+	}
+	return s.users[i], nil
+}
+
+func (s *Store) GetUserById(ctx context.Context, id uuid.UUID) (database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.userIndex(id)
+	if i < 0 {
+		return database.User{}, sql.ErrNoRows
+	}
+	return s.users[i], nil
+}
+
+func (s *Store) GetUsers(ctx context.Context) ([]database.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.users), nil
+}
+
+func (s *Store) MoveFeed(ctx context.Context, arg database.MoveFeedParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if slices.ContainsFunc(s.feeds, func(f database.Feed) bool { return f.Url == arg.Url && f.ID != arg.ID }) {
+		return violation(uniqueViolation, "feeds_url_key", "feeds")
+	}
+	s.updateFeed(arg.ID, func(f *database.Feed) {
+		f.Url = arg.Url
+		f.RedirectUrl = sql.NullString{}
+		f.RedirectCount = 0
+		f.UpdatedAt = now()
+	})
+	return nil
+}
+
+// Deletes the unstarred posts of a feed published before $2 or beyond its
+// newest $3 unstarred posts
+func (s *Store) PrunePosts(ctx context.Context, arg database.PrunePostsParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prune := s.postsToPrune(arg.FeedID, arg.PublishedAt, arg.Limit)
+	s.posts = slices.DeleteFunc(s.posts, func(post database.Post) bool {
+		return prune[post.ID]
+	})
+	return int64(len(prune)), nil
+}
+
+// Counts consecutive fetches that were permanently redirected to the same url
+func (s *Store) RecordFeedRedirect(ctx context.Context, arg database.RecordFeedRedirectParams) (int32, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	feed, err := s.updateFeed(arg.ID, func(f *database.Feed) {
+		if f.RedirectUrl.Valid && arg.RedirectUrl.Valid && f.RedirectUrl.String == arg.RedirectUrl.String {
+			f.RedirectCount++
+		} else {
+			f.RedirectCount = 1
+		}
+		f.RedirectUrl = arg.RedirectUrl
+	})
+	return feed.RedirectCount, err
+}
+
+// Inserts a feed from a backup with all of its fetch state
+func (s *Store) RestoreFeed(ctx context.Context, arg database.RestoreFeedParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if slices.ContainsFunc(s.feeds, func(f database.Feed) bool { return f.ID == arg.ID }) {
+		return violation(uniqueViolation, "feeds_pkey", "feeds")
+	}
+	if slices.ContainsFunc(s.feeds, func(f database.Feed) bool { return f.Url == arg.Url }) {
+		return violation(uniqueViolation, "feeds_url_key", "feeds")
+	}
+	if s.userIndex(arg.UserID) < 0 {
+		return violation(foreignKeyViolation, "fk_users", "feeds")
+	}
+
+	s.feeds = append(s.feeds, database.Feed(arg))
+	return nil
+}
+
+// Inserts a post from a backup, keeping its id and timestamps
+func (s *Store) RestorePost(ctx context.Context, arg database.RestorePostParams) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if slices.ContainsFunc(s.posts, func(p database.Post) bool { return p.ID == arg.ID }) {
+		return violation(uniqueViolation, "posts_pkey", "posts")
+	}
+	if slices.ContainsFunc(s.posts, func(p database.Post) bool { return p.Url == arg.Url }) {
+		return violation(uniqueViolation, "posts_url_key", "posts")
+	}
+	if s.feedIndex(arg.FeedID) < 0 {
+		return violation(foreignKeyViolation, "fk_feeds", "posts")
+	}
+
+	s.posts = append(s.posts, database.Post(arg))
+	return nil
+}
+
+func (s *Store) RetireFeed(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	retired := now()
+	s.updateFeed(id, func(f *database.Feed) {
+		f.RetiredAt = sql.NullTime{Time: retired, Valid: true}
+		f.UpdatedAt = retired
+	})
+	return nil
+}
+
+func (s *Store) SetPostStarred(ctx context.Context, arg database.SetPostStarredParams) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.posts, func(p database.Post) bool { return p.ID == arg.ID })
+	if i < 0 {
+		return 0, nil
+	}
+	s.posts[i].Starred = arg.Starred
+	s.posts[i].UpdatedAt = now()
+	return 1, nil
+}
+
+func (s *Store) UpdateFeedFetchTime(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fetched := now()
+	s.updateFeed(id, func(f *database.Feed) {
+		f.LastFetchedAt = sql.NullTime{Time: fetched, Valid: true}
+		f.UpdatedAt = fetched
+	})
+	return nil
+}
+
+func (s *Store) UpdateFeedName(ctx context.Context, arg database.UpdateFeedNameParams) (database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.updateFeed(arg.ID, func(f *database.Feed) {
+		f.Name = arg.Name
+		f.UpdatedAt = now()
+	})
+}
+
+func (s *Store) UpdateFeedUrl(ctx context.Context, arg database.UpdateFeedUrlParams) (database.Feed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if slices.ContainsFunc(s.feeds, func(f database.Feed) bool { return f.Url == arg.Url && f.ID != arg.ID }) {
+		return database.Feed{}, violation(uniqueViolation, "feeds_url_key", "feeds")
+	}
+	return s.updateFeed(arg.ID, func(f *database.Feed) {
+		f.Url = arg.Url
+		f.RedirectUrl = sql.NullString{}
+		f.RedirectCount = 0
+		f.RetiredAt = sql.NullTime{}
+		f.UpdatedAt = now()
+	})
+}
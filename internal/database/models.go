@@ -19,6 +19,11 @@ type Feed struct {
 	Url           string
 	UserID        uuid.UUID
 	LastFetchedAt sql.NullTime
+	RedirectUrl   sql.NullString
+	RedirectCount int32
+	RetiredAt     sql.NullTime
+	SiteUrl       string
+	Description   string
 }
 
 type FeedFollow struct {
@@ -38,6 +43,7 @@ type Post struct {
 	Description string
 	PublishedAt time.Time
 	FeedID      uuid.UUID
+	Starred     bool
 }
 
 type User struct {
@@ -13,26 +13,69 @@ import (
 	"github.com/google/uuid"
 )
 
+const clearFeedRedirect = `-- name: ClearFeedRedirect :exec
+UPDATE feeds
+SET redirect_url = NULL,
+    redirect_count = 0
+WHERE id = $1 AND redirect_url IS NOT NULL
+`
+
+func (q *Queries) ClearFeedRedirect(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, clearFeedRedirect, id)
+	return err
+}
+
+const countPostsToPrune = `-- name: CountPostsToPrune :one
+SELECT COUNT(*) FROM posts
+WHERE feed_id = $1
+  AND NOT starred
+  AND (published_at < $2 OR id NOT IN (
+    SELECT kept.id FROM posts AS kept
+    WHERE kept.feed_id = $1 AND NOT kept.starred
+    ORDER BY kept.published_at DESC, kept.created_at DESC
+    LIMIT $3
+  ))
+`
+
+type CountPostsToPruneParams struct {
+	FeedID      uuid.UUID
+	PublishedAt time.Time
+	Limit       int32
+}
+
+// Counts the unstarred posts of a feed published before $2 or beyond its
+// newest $3 unstarred posts
+func (q *Queries) CountPostsToPrune(ctx context.Context, arg CountPostsToPruneParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countPostsToPrune, arg.FeedID, arg.PublishedAt, arg.Limit)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createFeed = `-- name: CreateFeed :one
-INSERT INTO feeds (id, created_at, updated_at, name, url, user_id)
+INSERT INTO feeds (id, created_at, updated_at, name, url, user_id, site_url, description)
 VALUES (
   $1,
   $2,
   $3,
   $4,
   $5,
-  $6
+  $6,
+  $7,
+  $8
 )
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, redirect_url, redirect_count, retired_at, site_url, description
 `
 
 type CreateFeedParams struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Name      string
-	Url       string
-	UserID    uuid.UUID
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Name        string
+	Url         string
+	UserID      uuid.UUID
+	SiteUrl     string
+	Description string
 }
 
 func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, error) {
@@ -43,6 +86,8 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		arg.Name,
 		arg.Url,
 		arg.UserID,
+		arg.SiteUrl,
+		arg.Description,
 	)
 	var i Feed
 	err := row.Scan(
@@ -53,6 +98,11 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.RetiredAt,
+		&i.SiteUrl,
+		&i.Description,
 	)
 	return i, err
 }
@@ -145,7 +195,7 @@ VALUES (
     $4,
     $5
 )
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
+RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, starred
 `
 
 type CreatePostParams struct {
@@ -174,6 +224,7 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.Description,
 		&i.PublishedAt,
 		&i.FeedID,
+		&i.Starred,
 	)
 	return i, err
 }
@@ -231,6 +282,15 @@ func (q *Queries) DeleteAllUsers(ctx context.Context) error {
 	return err
 }
 
+const deleteFeed = `-- name: DeleteFeed :exec
+DELETE FROM feeds WHERE id = $1
+`
+
+func (q *Queries) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteFeed, id)
+	return err
+}
+
 const deleteFeedFollows = `-- name: DeleteFeedFollows :execresult
 DELETE FROM feed_follows WHERE user_id = $1 AND feed_id = $2
 `
@@ -278,7 +338,7 @@ func (q *Queries) GetAllFeedFollows(ctx context.Context) ([]FeedFollow, error) {
 }
 
 const getAllFeeds = `-- name: GetAllFeeds :many
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, redirect_url, redirect_count, retired_at, site_url, description FROM feeds
 `
 
 func (q *Queries) GetAllFeeds(ctx context.Context) ([]Feed, error) {
@@ -298,6 +358,49 @@ func (q *Queries) GetAllFeeds(ctx context.Context) ([]Feed, error) {
 			&i.Url,
 			&i.UserID,
 			&i.LastFetchedAt,
+			&i.RedirectUrl,
+			&i.RedirectCount,
+			&i.RetiredAt,
+			&i.SiteUrl,
+			&i.Description,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAllPosts = `-- name: GetAllPosts :many
+SELECT id, created_at, updated_at, title, url, description, published_at, feed_id, starred FROM posts
+ORDER BY feed_id, published_at
+`
+
+func (q *Queries) GetAllPosts(ctx context.Context) ([]Post, error) {
+	rows, err := q.db.QueryContext(ctx, getAllPosts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Post
+	for rows.Next() {
+		var i Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Starred,
 		); err != nil {
 			return nil, err
 		}
@@ -313,7 +416,7 @@ func (q *Queries) GetAllFeeds(ctx context.Context) ([]Feed, error) {
 }
 
 const getFeed = `-- name: GetFeed :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, redirect_url, redirect_count, retired_at, site_url, description FROM feeds
 WHERE url = $1 LIMIT 1
 `
 
@@ -328,12 +431,17 @@ func (q *Queries) GetFeed(ctx context.Context, url string) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.RetiredAt,
+		&i.SiteUrl,
+		&i.Description,
 	)
 	return i, err
 }
 
 const getFeedsByUser = `-- name: GetFeedsByUser :many
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, redirect_url, redirect_count, retired_at, site_url, description FROM feeds
 WHERE user_id = $1
 `
 
@@ -354,6 +462,11 @@ func (q *Queries) GetFeedsByUser(ctx context.Context, userID uuid.UUID) ([]Feed,
 			&i.Url,
 			&i.UserID,
 			&i.LastFetchedAt,
+			&i.RedirectUrl,
+			&i.RedirectCount,
+			&i.RetiredAt,
+			&i.SiteUrl,
+			&i.Description,
 		); err != nil {
 			return nil, err
 		}
@@ -374,6 +487,7 @@ SELECT
   ff.user_id,
   ff.feed_id,
   f.name AS feed_name,
+  f.url AS feed_url,
   u.name AS user_name
 FROM feed_follows ff
 INNER JOIN feeds f ON ff.feed_id = f.id
@@ -386,6 +500,7 @@ type GetFollowsByUserRow struct {
 	UserID   uuid.UUID
 	FeedID   uuid.UUID
 	FeedName string
+	FeedUrl  string
 	UserName string
 }
 
@@ -403,6 +518,7 @@ func (q *Queries) GetFollowsByUser(ctx context.Context, userID uuid.UUID) ([]Get
 			&i.UserID,
 			&i.FeedID,
 			&i.FeedName,
+			&i.FeedUrl,
 			&i.UserName,
 		); err != nil {
 			return nil, err
@@ -421,6 +537,7 @@ func (q *Queries) GetFollowsByUser(ctx context.Context, userID uuid.UUID) ([]Get
 const getNextFeedToFetch = `-- name: GetNextFeedToFetch :one
 SELECT id, created_at, updated_at, last_fetched_at, name, url, user_id
 FROM feeds
+WHERE retired_at IS NULL
 ORDER BY 
     -- Prioritize feeds that have never been fetched (NULL values first)
     CASE WHEN last_fetched_at IS NULL THEN 0 ELSE 1 END,
@@ -456,8 +573,29 @@ func (q *Queries) GetNextFeedToFetch(ctx context.Context) (GetNextFeedToFetchRow
 	return i, err
 }
 
+const getNthNewestPostDate = `-- name: GetNthNewestPostDate :one
+SELECT published_at FROM posts
+WHERE feed_id = $1 AND NOT starred
+ORDER BY published_at DESC, created_at DESC
+LIMIT 1 OFFSET $2
+`
+
+type GetNthNewestPostDateParams struct {
+	FeedID uuid.UUID
+	Offset int32
+}
+
+// Returns the publish time of a feed's unstarred post at offset $2 in
+// prune order
+func (q *Queries) GetNthNewestPostDate(ctx context.Context, arg GetNthNewestPostDateParams) (time.Time, error) {
+	row := q.db.QueryRowContext(ctx, getNthNewestPostDate, arg.FeedID, arg.Offset)
+	var published_at time.Time
+	err := row.Scan(&published_at)
+	return published_at, err
+}
+
 const getPostsForUser = `-- name: GetPostsForUser :many
-SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, feeds.name AS feed_name 
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.starred, feeds.name AS feed_name 
 FROM posts 
 INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id 
 INNER JOIN feeds ON posts.feed_id = feeds.id
@@ -480,6 +618,7 @@ type GetPostsForUserRow struct {
 	Description string
 	PublishedAt time.Time
 	FeedID      uuid.UUID
+	Starred     bool
 	FeedName    string
 }
 
@@ -501,6 +640,7 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
+			&i.Starred,
 			&i.FeedName,
 		); err != nil {
 			return nil, err
@@ -582,6 +722,201 @@ func (q *Queries) GetUsers(ctx context.Context) ([]User, error) {
 	return items, nil
 }
 
+const moveFeed = `-- name: MoveFeed :exec
+UPDATE feeds
+SET url = $2,
+    redirect_url = NULL,
+    redirect_count = 0,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type MoveFeedParams struct {
+	ID  uuid.UUID
+	Url string
+}
+
+func (q *Queries) MoveFeed(ctx context.Context, arg MoveFeedParams) error {
+	_, err := q.db.ExecContext(ctx, moveFeed, arg.ID, arg.Url)
+	return err
+}
+
+const prunePosts = `-- name: PrunePosts :execrows
+DELETE FROM posts
+WHERE feed_id = $1
+  AND NOT starred
+  AND (published_at < $2 OR id NOT IN (
+    SELECT kept.id FROM posts AS kept
+    WHERE kept.feed_id = $1 AND NOT kept.starred
+    ORDER BY kept.published_at DESC, kept.created_at DESC
+    LIMIT $3
+  ))
+`
+
+type PrunePostsParams struct {
+	FeedID      uuid.UUID
+	PublishedAt time.Time
+	Limit       int32
+}
+
+// Deletes the unstarred posts of a feed published before $2 or beyond its
+// newest $3 unstarred posts
+func (q *Queries) PrunePosts(ctx context.Context, arg PrunePostsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, prunePosts, arg.FeedID, arg.PublishedAt, arg.Limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const recordFeedRedirect = `-- name: RecordFeedRedirect :one
+UPDATE feeds
+SET redirect_count = CASE WHEN redirect_url = $2 THEN redirect_count + 1 ELSE 1 END,
+    redirect_url = $2
+WHERE id = $1
+RETURNING redirect_count
+`
+
+type RecordFeedRedirectParams struct {
+	ID          uuid.UUID
+	RedirectUrl sql.NullString
+}
+
+// Counts consecutive fetches that were permanently redirected to the same url
+func (q *Queries) RecordFeedRedirect(ctx context.Context, arg RecordFeedRedirectParams) (int32, error) {
+	row := q.db.QueryRowContext(ctx, recordFeedRedirect, arg.ID, arg.RedirectUrl)
+	var redirect_count int32
+	err := row.Scan(&redirect_count)
+	return redirect_count, err
+}
+
+const restoreFeed = `-- name: RestoreFeed :exec
+INSERT INTO feeds (id, created_at, updated_at, name, url, user_id, last_fetched_at, redirect_url, redirect_count, retired_at, site_url, description)
+VALUES (
+  $1,
+  $2,
+  $3,
+  $4,
+  $5,
+  $6,
+  $7,
+  $8,
+  $9,
+  $10,
+  $11,
+  $12
+)
+`
+
+type RestoreFeedParams struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Name          string
+	Url           string
+	UserID        uuid.UUID
+	LastFetchedAt sql.NullTime
+	RedirectUrl   sql.NullString
+	RedirectCount int32
+	RetiredAt     sql.NullTime
+	SiteUrl       string
+	Description   string
+}
+
+// Inserts a feed from a backup with all of its fetch state
+func (q *Queries) RestoreFeed(ctx context.Context, arg RestoreFeedParams) error {
+	_, err := q.db.ExecContext(ctx, restoreFeed,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Name,
+		arg.Url,
+		arg.UserID,
+		arg.LastFetchedAt,
+		arg.RedirectUrl,
+		arg.RedirectCount,
+		arg.RetiredAt,
+		arg.SiteUrl,
+		arg.Description,
+	)
+	return err
+}
+
+const restorePost = `-- name: RestorePost :exec
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, starred)
+VALUES (
+  $1,
+  $2,
+  $3,
+  $4,
+  $5,
+  $6,
+  $7,
+  $8,
+  $9
+)
+`
+
+type RestorePostParams struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description string
+	PublishedAt time.Time
+	FeedID      uuid.UUID
+	Starred     bool
+}
+
+// Inserts a post from a backup, keeping its id and timestamps
+func (q *Queries) RestorePost(ctx context.Context, arg RestorePostParams) error {
+	_, err := q.db.ExecContext(ctx, restorePost,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Title,
+		arg.Url,
+		arg.Description,
+		arg.PublishedAt,
+		arg.FeedID,
+		arg.Starred,
+	)
+	return err
+}
+
+const retireFeed = `-- name: RetireFeed :exec
+UPDATE feeds
+SET retired_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) RetireFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, retireFeed, id)
+	return err
+}
+
+const setPostStarred = `-- name: SetPostStarred :execrows
+UPDATE posts
+SET starred = $2,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type SetPostStarredParams struct {
+	ID      uuid.UUID
+	Starred bool
+}
+
+func (q *Queries) SetPostStarred(ctx context.Context, arg SetPostStarredParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setPostStarred, arg.ID, arg.Starred)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const updateFeedFetchTime = `-- name: UpdateFeedFetchTime :exec
 UPDATE feeds
 SET last_fetched_at = NOW(),
@@ -593,3 +928,72 @@ func (q *Queries) UpdateFeedFetchTime(ctx context.Context, id uuid.UUID) error {
 	_, err := q.db.ExecContext(ctx, updateFeedFetchTime, id)
 	return err
 }
+
+const updateFeedName = `-- name: UpdateFeedName :one
+UPDATE feeds
+SET name = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, redirect_url, redirect_count, retired_at, site_url, description
+`
+
+type UpdateFeedNameParams struct {
+	ID   uuid.UUID
+	Name string
+}
+
+func (q *Queries) UpdateFeedName(ctx context.Context, arg UpdateFeedNameParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, updateFeedName, arg.ID, arg.Name)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.RetiredAt,
+		&i.SiteUrl,
+		&i.Description,
+	)
+	return i, err
+}
+
+const updateFeedUrl = `-- name: UpdateFeedUrl :one
+UPDATE feeds
+SET url = $2,
+    redirect_url = NULL,
+    redirect_count = 0,
+    retired_at = NULL,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, redirect_url, redirect_count, retired_at, site_url, description
+`
+
+type UpdateFeedUrlParams struct {
+	ID  uuid.UUID
+	Url string
+}
+
+func (q *Queries) UpdateFeedUrl(ctx context.Context, arg UpdateFeedUrlParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, updateFeedUrl, arg.ID, arg.Url)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.RetiredAt,
+		&i.SiteUrl,
+		&i.Description,
+	)
+	return i, err
+}
@@ -0,0 +1,720 @@
+// Package sqlitedb implements database.Querier for the SQLite backend. The
+// queries mirror sql/queries/queries.sql in SQLite's dialect: ids are
+// bound as text, timestamps are written in UTC so that they sort
+// chronologically, and statements SQLite lacks (TRUNCATE, inserts inside
+// a WITH clause) are rewritten.
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/voylento/gator/internal/database"
+)
+
+func New(db database.DBTX) *Queries {
+	return &Queries{db: db}
+}
+
+type Queries struct {
+	db database.DBTX
+}
+
+var _ database.Querier = (*Queries)(nil)
+
+func (q *Queries) WithTx(tx *sql.Tx) *Queries {
+	return &Queries{
+		db: tx,
+	}
+}
+
+// Times are stored as text, so they only compare correctly in one zone
+func utc(t time.Time) time.Time {
+	return t.UTC()
+}
+
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+func nullUTC(t sql.NullTime) sql.NullTime {
+	if t.Valid {
+		t.Time = t.Time.UTC()
+	}
+	return t
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+const feedColumns = `id, created_at, updated_at, name, url, user_id, last_fetched_at, redirect_url, redirect_count, retired_at, site_url, description`
+
+func scanFeed(row scanner) (database.Feed, error) {
+	var i database.Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.RedirectUrl,
+		&i.RedirectCount,
+		&i.RetiredAt,
+		&i.SiteUrl,
+		&i.Description,
+	)
+	return i, err
+}
+
+func scanFeeds(rows *sql.Rows, err error) ([]database.Feed, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []database.Feed
+	for rows.Next() {
+		i, err := scanFeed(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func scanUser(row scanner) (database.User, error) {
+	var i database.User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+	)
+	return i, err
+}
+
+const clearFeedRedirect = `
+UPDATE feeds
+SET redirect_url = NULL,
+    redirect_count = 0
+WHERE id = ?1 AND redirect_url IS NOT NULL
+`
+
+func (q *Queries) ClearFeedRedirect(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, clearFeedRedirect, id)
+	return err
+}
+
+const countPostsToPrune = `
+SELECT COUNT(*) FROM posts
+WHERE feed_id = ?1
+  AND NOT starred
+  AND (published_at < ?2 OR id NOT IN (
+    SELECT kept.id FROM posts AS kept
+    WHERE kept.feed_id = ?1 AND NOT kept.starred
+    ORDER BY kept.published_at DESC, kept.created_at DESC
+    LIMIT ?3
+  ))
+`
+
+// Counts the unstarred posts of a feed published before $2 or beyond its
+// newest $3 unstarred posts
+func (q *Queries) CountPostsToPrune(ctx context.Context, arg database.CountPostsToPruneParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countPostsToPrune, arg.FeedID, utc(arg.PublishedAt), arg.Limit)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createFeed = `
+INSERT INTO feeds (id, created_at, updated_at, name, url, user_id, site_url, description)
+VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8)
+RETURNING ` + feedColumns
+
+func (q *Queries) CreateFeed(ctx context.Context, arg database.CreateFeedParams) (database.Feed, error) {
+	row := q.db.QueryRowContext(ctx, createFeed,
+		arg.ID,
+		utc(arg.CreatedAt),
+		utc(arg.UpdatedAt),
+		arg.Name,
+		arg.Url,
+		arg.UserID,
+		arg.SiteUrl,
+		arg.Description,
+	)
+	return scanFeed(row)
+}
+
+const createFeedFollow = `
+INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id)
+VALUES (?1, ?2, ?3, ?4, ?5)
+`
+
+const getFeedFollowRow = `
+SELECT
+  ff.id, ff.created_at, ff.updated_at, ff.user_id, ff.feed_id,
+  f.name AS feed_name,
+  u.name AS user_name
+FROM feed_follows ff
+INNER JOIN feeds f ON ff.feed_id = f.id
+INNER JOIN users u ON ff.user_id = u.id
+WHERE ff.id = ?1
+`
+
+// SQLite has no inserts inside WITH, so the follow is inserted and then
+// read back joined with its feed and user
+func (q *Queries) CreateFeedFollow(ctx context.Context, arg database.CreateFeedFollowParams) ([]database.CreateFeedFollowRow, error) {
+	_, err := q.db.ExecContext(ctx, createFeedFollow,
+		arg.ID,
+		utc(arg.CreatedAt),
+		utc(arg.UpdatedAt),
+		arg.UserID,
+		arg.FeedID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var i database.CreateFeedFollowRow
+	err = q.db.QueryRowContext(ctx, getFeedFollowRow, arg.ID).Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.FeedID,
+		&i.FeedName,
+		&i.UserName,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return []database.CreateFeedFollowRow{i}, nil
+}
+
+const createPost = `
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
+VALUES (?1, ?2, ?2, ?3, ?4, ?5, ?6, ?7)
+RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, starred
+`
+
+func (q *Queries) CreatePost(ctx context.Context, arg database.CreatePostParams) (database.Post, error) {
+	row := q.db.QueryRowContext(ctx, createPost,
+		uuid.New(),
+		now(),
+		arg.Title,
+		arg.Url,
+		arg.Description,
+		utc(arg.PublishedAt),
+		arg.FeedID,
+	)
+	var i database.Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.Starred,
+	)
+	return i, err
+}
+
+const createUser = `
+INSERT INTO users (id, created_at, updated_at, name)
+VALUES (?1, ?2, ?3, ?4)
+RETURNING id, created_at, updated_at, name
+`
+
+func (q *Queries) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	row := q.db.QueryRowContext(ctx, createUser,
+		arg.ID,
+		utc(arg.CreatedAt),
+		utc(arg.UpdatedAt),
+		arg.Name,
+	)
+	return scanUser(row)
+}
+
+const deleteAllFeeds = `
+DELETE FROM feeds
+`
+
+func (q *Queries) DeleteAllFeeds(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, deleteAllFeeds)
+	return err
+}
+
+const deleteAllUsers = `
+DELETE FROM users
+`
+
+func (q *Queries) DeleteAllUsers(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, deleteAllUsers)
+	return err
+}
+
+const deleteFeed = `
+DELETE FROM feeds WHERE id = ?1
+`
+
+func (q *Queries) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteFeed, id)
+	return err
+}
+
+const deleteFeedFollows = `
+DELETE FROM feed_follows WHERE user_id = ?1 AND feed_id = ?2
+`
+
+func (q *Queries) DeleteFeedFollows(ctx context.Context, arg database.DeleteFeedFollowsParams) (sql.Result, error) {
+	return q.db.ExecContext(ctx, deleteFeedFollows, arg.UserID, arg.FeedID)
+}
+
+const getAllFeedFollows = `
+SELECT id, created_at, updated_at, user_id, feed_id FROM feed_follows
+`
+
+func (q *Queries) GetAllFeedFollows(ctx context.Context) ([]database.FeedFollow, error) {
+	rows, err := q.db.QueryContext(ctx, getAllFeedFollows)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []database.FeedFollow
+	for rows.Next() {
+		var i database.FeedFollow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.FeedID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getAllFeeds = `
+SELECT ` + feedColumns + ` FROM feeds
+`
+
+func (q *Queries) GetAllFeeds(ctx context.Context) ([]database.Feed, error) {
+	return scanFeeds(q.db.QueryContext(ctx, getAllFeeds))
+}
+
+const getAllPosts = `
+SELECT id, created_at, updated_at, title, url, description, published_at, feed_id, starred FROM posts
+ORDER BY feed_id, published_at
+`
+
+func (q *Queries) GetAllPosts(ctx context.Context) ([]database.Post, error) {
+	rows, err := q.db.QueryContext(ctx, getAllPosts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []database.Post
+	for rows.Next() {
+		var i database.Post
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Starred,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFeed = `
+SELECT ` + feedColumns + ` FROM feeds
+WHERE url = ?1 LIMIT 1
+`
+
+func (q *Queries) GetFeed(ctx context.Context, url string) (database.Feed, error) {
+	return scanFeed(q.db.QueryRowContext(ctx, getFeed, url))
+}
+
+const getFeedsByUser = `
+SELECT ` + feedColumns + ` FROM feeds
+WHERE user_id = ?1
+`
+
+func (q *Queries) GetFeedsByUser(ctx context.Context, userID uuid.UUID) ([]database.Feed, error) {
+	return scanFeeds(q.db.QueryContext(ctx, getFeedsByUser, userID))
+}
+
+const getFollowsByUser = `
+SELECT
+  ff.id,
+  ff.user_id,
+  ff.feed_id,
+  f.name AS feed_name,
+  f.url AS feed_url,
+  u.name AS user_name
+FROM feed_follows ff
+INNER JOIN feeds f ON ff.feed_id = f.id
+INNER JOIN users u ON ff.user_id = u.id
+WHERE ff.user_id = ?1
+`
+
+func (q *Queries) GetFollowsByUser(ctx context.Context, userID uuid.UUID) ([]database.GetFollowsByUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFollowsByUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []database.GetFollowsByUserRow
+	for rows.Next() {
+		var i database.GetFollowsByUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.FeedID,
+			&i.FeedName,
+			&i.FeedUrl,
+			&i.UserName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getNextFeedToFetch = `
+SELECT id, created_at, updated_at, last_fetched_at, name, url, user_id
+FROM feeds
+WHERE retired_at IS NULL
+ORDER BY
+    -- Prioritize feeds that have never been fetched
+    last_fetched_at IS NOT NULL,
+    -- Then sort by oldest fetch time
+    last_fetched_at ASC,
+    id
+LIMIT 1
+`
+
+func (q *Queries) GetNextFeedToFetch(ctx context.Context) (database.GetNextFeedToFetchRow, error) {
+	row := q.db.QueryRowContext(ctx, getNextFeedToFetch)
+	var i database.GetNextFeedToFetchRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.LastFetchedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+	)
+	return i, err
+}
+
+const getNthNewestPostDate = `
+SELECT published_at FROM posts
+WHERE feed_id = ?1 AND NOT starred
+ORDER BY published_at DESC, created_at DESC
+LIMIT 1 OFFSET ?2
+`
+
+// Returns the publish time of a feed's unstarred post at offset $2 in
+// prune order
+func (q *Queries) GetNthNewestPostDate(ctx context.Context, arg database.GetNthNewestPostDateParams) (time.Time, error) {
+	row := q.db.QueryRowContext(ctx, getNthNewestPostDate, arg.FeedID, arg.Offset)
+	var published_at time.Time
+	err := row.Scan(&published_at)
+	return published_at, err
+}
+
+const getPostsForUser = `
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.starred, feeds.name AS feed_name
+FROM posts
+INNER JOIN feed_follows ON posts.feed_id = feed_follows.feed_id
+INNER JOIN feeds ON posts.feed_id = feeds.id
+WHERE feed_follows.user_id = ?1
+ORDER BY posts.published_at DESC
+LIMIT ?2
+`
+
+func (q *Queries) GetPostsForUser(ctx context.Context, arg database.GetPostsForUserParams) ([]database.GetPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPostsForUser, arg.UserID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []database.GetPostsForUserRow
+	for rows.Next() {
+		var i database.GetPostsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Starred,
+			&i.FeedName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUser = `
+SELECT id, created_at, updated_at, name FROM users
+WHERE name = ?1 LIMIT 1
+`
+
+func (q *Queries) GetUser(ctx context.Context, name string) (database.User, error) {
+	return scanUser(q.db.QueryRowContext(ctx, getUser, name))
+}
+
+const getUserById = `
+SELECT id, created_at, updated_at, name FROM users
+WHERE id = ?1 LIMIT 1
+`
+
+func (q *Queries) GetUserById(ctx context.Context, id uuid.UUID) (database.User, error) {
+	return scanUser(q.db.QueryRowContext(ctx, getUserById, id))
+}
+
+const getUsers = `
+SELECT id, created_at, updated_at, name FROM users
+`
+
+func (q *Queries) GetUsers(ctx context.Context) ([]database.User, error) {
+	rows, err := q.db.QueryContext(ctx, getUsers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []database.User
+	for rows.Next() {
+		i, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const moveFeed = `
+UPDATE feeds
+SET url = ?2,
+    redirect_url = NULL,
+    redirect_count = 0,
+    updated_at = ?3
+WHERE id = ?1
+`
+
+func (q *Queries) MoveFeed(ctx context.Context, arg database.MoveFeedParams) error {
+	_, err := q.db.ExecContext(ctx, moveFeed, arg.ID, arg.Url, now())
+	return err
+}
+
+const prunePosts = `
+DELETE FROM posts
+WHERE feed_id = ?1
+  AND NOT starred
+  AND (published_at < ?2 OR id NOT IN (
+    SELECT kept.id FROM posts AS kept
+    WHERE kept.feed_id = ?1 AND NOT kept.starred
+    ORDER BY kept.published_at DESC, kept.created_at DESC
+    LIMIT ?3
+  ))
+`
+
+// Deletes the unstarred posts of a feed published before $2 or beyond its
+// newest $3 unstarred posts
+func (q *Queries) PrunePosts(ctx context.Context, arg database.PrunePostsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, prunePosts, arg.FeedID, utc(arg.PublishedAt), arg.Limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const recordFeedRedirect = `
+UPDATE feeds
+SET redirect_count = CASE WHEN redirect_url = ?2 THEN redirect_count + 1 ELSE 1 END,
+    redirect_url = ?2
+WHERE id = ?1
+RETURNING redirect_count
+`
+
+// Counts consecutive fetches that were permanently redirected to the same url
+func (q *Queries) RecordFeedRedirect(ctx context.Context, arg database.RecordFeedRedirectParams) (int32, error) {
+	row := q.db.QueryRowContext(ctx, recordFeedRedirect, arg.ID, arg.RedirectUrl)
+	var redirect_count int32
+	err := row.Scan(&redirect_count)
+	return redirect_count, err
+}
+
+const restoreFeed = `
+INSERT INTO feeds (id, created_at, updated_at, name, url, user_id, last_fetched_at, redirect_url, redirect_count, retired_at, site_url, description)
+VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11, ?12)
+`
+
+// Inserts a feed from a backup with all of its fetch state
+func (q *Queries) RestoreFeed(ctx context.Context, arg database.RestoreFeedParams) error {
+	_, err := q.db.ExecContext(ctx, restoreFeed,
+		arg.ID,
+		utc(arg.CreatedAt),
+		utc(arg.UpdatedAt),
+		arg.Name,
+		arg.Url,
+		arg.UserID,
+		nullUTC(arg.LastFetchedAt),
+		arg.RedirectUrl,
+		arg.RedirectCount,
+		nullUTC(arg.RetiredAt),
+		arg.SiteUrl,
+		arg.Description,
+	)
+	return err
+}
+
+const restorePost = `
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, starred)
+VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9)
+`
+
+// Inserts a post from a backup, keeping its id and timestamps
+func (q *Queries) RestorePost(ctx context.Context, arg database.RestorePostParams) error {
+	_, err := q.db.ExecContext(ctx, restorePost,
+		arg.ID,
+		utc(arg.CreatedAt),
+		utc(arg.UpdatedAt),
+		arg.Title,
+		arg.Url,
+		arg.Description,
+		utc(arg.PublishedAt),
+		arg.FeedID,
+		arg.Starred,
+	)
+	return err
+}
+
+const retireFeed = `
+UPDATE feeds
+SET retired_at = ?2,
+    updated_at = ?2
+WHERE id = ?1
+`
+
+func (q *Queries) RetireFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, retireFeed, id, now())
+	return err
+}
+
+const setPostStarred = `
+UPDATE posts
+SET starred = ?2,
+    updated_at = ?3
+WHERE id = ?1
+`
+
+func (q *Queries) SetPostStarred(ctx context.Context, arg database.SetPostStarredParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, setPostStarred, arg.ID, arg.Starred, now())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateFeedFetchTime = `
+UPDATE feeds
+SET last_fetched_at = ?2,
+    updated_at = ?2
+WHERE id = ?1
+`
+
+func (q *Queries) UpdateFeedFetchTime(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, updateFeedFetchTime, id, now())
+	return err
+}
+
+const updateFeedName = `
+UPDATE feeds
+SET name = ?2,
+    updated_at = ?3
+WHERE id = ?1
+RETURNING ` + feedColumns
+
+func (q *Queries) UpdateFeedName(ctx context.Context, arg database.UpdateFeedNameParams) (database.Feed, error) {
+	return scanFeed(q.db.QueryRowContext(ctx, updateFeedName, arg.ID, arg.Name, now()))
+}
+
+const updateFeedUrl = `
+UPDATE feeds
+SET url = ?2,
+    redirect_url = NULL,
+    redirect_count = 0,
+    retired_at = NULL,
+    updated_at = ?3
+WHERE id = ?1
+RETURNING ` + feedColumns
+
+func (q *Queries) UpdateFeedUrl(ctx context.Context, arg database.UpdateFeedUrlParams) (database.Feed, error) {
+	return scanFeed(q.db.QueryRowContext(ctx, updateFeedUrl, arg.ID, arg.Url, now()))
+}
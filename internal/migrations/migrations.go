@@ -0,0 +1,111 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"github.com/pressly/goose/v3"
+	"github.com/voylento/gator/internal/storage"
+	"github.com/voylento/gator/sql/schema"
+	sqliteschema "github.com/voylento/gator/sql/schema/sqlite"
+)
+
+// Status of a single migration embedded in the binary
+type Status struct {
+	Version   int64
+	Source    string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies the schema migrations embedded in the binary
+type Migrator struct {
+	provider *goose.Provider
+}
+
+// Each backend has its own migrations in its own SQL dialect
+func New(db *storage.DB) (*Migrator, error) {
+	dialect, migrations := goose.DialectPostgres, fs.FS(schema.FS)
+	if db.Backend == storage.SQLite {
+		dialect, migrations = goose.DialectSQLite3, sqliteschema.FS
+	}
+
+	provider, err := goose.NewProvider(dialect, db.DB, migrations)
+	if err != nil {
+		return nil, fmt.Errorf("Error loading migrations: %v", err)
+	}
+
+	return &Migrator{provider: provider}, nil
+}
+
+// Applies all pending migrations and returns the versions that were applied
+func (m *Migrator) Up(ctx context.Context) ([]int64, error) {
+	results, err := m.provider.Up(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Error applying migrations: %v", err)
+	}
+
+	versions := make([]int64, 0, len(results))
+	for _, result := range results {
+		versions = append(versions, result.Source.Version)
+	}
+	return versions, nil
+}
+
+// Rolls back the most recently applied migration and returns its version
+func (m *Migrator) Down(ctx context.Context) (int64, error) {
+	result, err := m.provider.Down(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("Error rolling back migration: %v", err)
+	}
+
+	return result.Source.Version, nil
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	results, err := m.provider.Status(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading migration status: %v", err)
+	}
+
+	statuses := make([]Status, 0, len(results))
+	for _, result := range results {
+		statuses = append(statuses, Status{
+			Version:   result.Source.Version,
+			Source:    result.Source.Path,
+			Applied:   result.State == goose.StateApplied,
+			AppliedAt: result.AppliedAt,
+		})
+	}
+	return statuses, nil
+}
+
+// Returns the schema version of the database and the latest version
+// embedded in the binary
+func (m *Migrator) Versions(ctx context.Context) (current, latest int64, err error) {
+	current, latest, err = m.provider.GetVersions(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("Error reading schema version: %v", err)
+	}
+	return current, latest, nil
+}
+
+// Returns an error describing how to fix the database when its schema is
+// behind the migrations embedded in the binary
+func (m *Migrator) CheckCurrent(ctx context.Context) error {
+	pending, err := m.provider.HasPending(ctx)
+	if err != nil {
+		return fmt.Errorf("Error checking database schema: %v", err)
+	}
+	if !pending {
+		return nil
+	}
+
+	current, latest, err := m.Versions(ctx)
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("Database schema is out of date (version %d, gator requires %d). Run 'gator migrate up' to update it.", current, latest)
+}
@@ -0,0 +1,164 @@
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Format selects how listing commands render their records
+type Format string
+
+const (
+	Text Format = "text"
+	JSON Format = "json"
+	CSV  Format = "csv"
+	TSV  Format = "tsv"
+)
+
+var formats = []Format{Text, JSON, CSV, TSV}
+
+// Table is a list of records sharing the same columns. Each row
+// must have one value per column.
+type Table struct {
+	Columns []string
+	Rows    [][]any
+}
+
+func (t *Table) Append(values ...any) {
+	t.Rows = append(t.Rows, values)
+}
+
+func ParseFormat(name string) (Format, error) {
+	for _, f := range formats {
+		if string(f) == strings.ToLower(name) {
+			return f, nil
+		}
+	}
+
+	names := make([]string, len(formats))
+	for i, f := range formats {
+		names[i] = string(f)
+	}
+	return "", fmt.Errorf("unknown output format %q (expected one of %s)", name, strings.Join(names, ", "))
+}
+
+// Write renders the table in a structured format. Text output is left
+// to the caller since each command has its own human readable layout.
+func Write(w io.Writer, f Format, t Table) error {
+	switch f {
+	case JSON:
+		return writeJSON(w, t)
+	case CSV:
+		return writeDelimited(w, ',', t)
+	case TSV:
+		return writeTSV(w, t)
+	default:
+		return fmt.Errorf("output format %q is not a structured format", f)
+	}
+}
+
+func writeJSON(w io.Writer, t Table) error {
+	// Build each object by hand so that keys keep the column order
+	// instead of the alphabetical order of a map
+	var b strings.Builder
+	b.WriteString("[")
+	for i, row := range t.Rows {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n  {")
+		for j, col := range t.Columns {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			key, err := json.Marshal(col)
+			if err != nil {
+				return err
+			}
+			value, err := json.Marshal(row[j])
+			if err != nil {
+				return err
+			}
+			b.Write(key)
+			b.WriteString(": ")
+			b.Write(value)
+		}
+		b.WriteString("}")
+	}
+	if len(t.Rows) > 0 {
+		b.WriteString("\n")
+	}
+	b.WriteString("]\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Tabs, newlines and backslashes inside a field are written as \t, \n, \r
+// and \\ (the "linear TSV" convention also used by PostgreSQL COPY), so
+// every record is a single line and fields are never quoted
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+func writeTSV(w io.Writer, t Table) error {
+	var b strings.Builder
+	writeRecord := func(fields []string) {
+		for j, field := range fields {
+			if j > 0 {
+				b.WriteByte('\t')
+			}
+			b.WriteString(tsvEscaper.Replace(field))
+		}
+		b.WriteByte('\n')
+	}
+
+	writeRecord(t.Columns)
+	record := make([]string, len(t.Columns))
+	for _, row := range t.Rows {
+		for j := range t.Columns {
+			record[j] = formatValue(row[j])
+		}
+		writeRecord(record)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeDelimited(w io.Writer, sep rune, t Table) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = sep
+
+	if err := cw.Write(t.Columns); err != nil {
+		return err
+	}
+
+	record := make([]string, len(t.Columns))
+	for _, row := range t.Rows {
+		for j := range t.Columns {
+			record[j] = formatValue(row[j])
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,54 @@
+package output
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteDelimited(t *testing.T) {
+	table := Table{Columns: []string{"id", "title", "published_at"}}
+	table.Append(1, `Say "hi"`, time.Date(2026, 10, 5, 9, 0, 0, 0, time.UTC))
+	table.Append(2, "tab\there", nil)
+	table.Append(3, "two\nlines\r", "back\\slash, comma")
+
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{CSV, "id,title,published_at\n" +
+			"1,\"Say \"\"hi\"\"\",2026-10-05T09:00:00Z\n" +
+			"2,tab\there,\n" +
+			"3,\"two\nlines\r\",\"back\\slash, comma\"\n"},
+		{TSV, "id\ttitle\tpublished_at\n" +
+			"1\tSay \"hi\"\t2026-10-05T09:00:00Z\n" +
+			"2\ttab\\there\t\n" +
+			"3\ttwo\\nlines\\r\tback\\\\slash, comma\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var b strings.Builder
+			if err := Write(&b, tt.format, table); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			if got := b.String(); got != tt.want {
+				t.Errorf("Write(%v) =\n%q\nwant\n%q", tt.format, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteJSONKeepsColumnOrder(t *testing.T) {
+	table := Table{Columns: []string{"name", "id"}}
+	table.Append("tab\there", 7)
+
+	var b strings.Builder
+	if err := Write(&b, JSON, table); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	want := "[\n  {\"name\": \"tab\\there\", \"id\": 7}\n]\n"
+	if got := b.String(); got != want {
+		t.Errorf("Write(json) = %q, want %q", got, want)
+	}
+}
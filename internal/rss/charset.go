@@ -0,0 +1,67 @@
+package rss
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+// Creates a decoder that reads feeds in legacy encodings such as
+// ISO-8859-1 or windows-1252. A charset in the Content-Type header,
+// including utf-8, takes precedence over the encoding in the XML
+// declaration (RFC 7303), so the body is converted up front and the
+// declaration is then ignored. Otherwise the declaration selects the
+// encoding.
+func newXMLDecoder(body []byte, contentType string) (*xml.Decoder, error) {
+	label := contentTypeCharset(contentType)
+	if label == "" {
+		decoder := xml.NewDecoder(bytes.NewReader(body))
+		decoder.CharsetReader = charsetReader
+		return decoder, nil
+	}
+
+	var reader io.Reader = bytes.NewReader(body)
+	if !isUTF8(label) {
+		var err error
+		reader, err = charset.NewReaderLabel(label, reader)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported charset %q: %v", label, err)
+		}
+	}
+
+	decoder := xml.NewDecoder(reader)
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	return decoder, nil
+}
+
+// Called by the decoder for encodings other than UTF-8
+func charsetReader(label string, input io.Reader) (io.Reader, error) {
+	reader, err := charset.NewReaderLabel(label, input)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported charset %q: %v", label, err)
+	}
+	return reader, nil
+}
+
+func contentTypeCharset(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(params["charset"])
+}
+
+func isUTF8(label string) bool {
+	label = strings.ToLower(label)
+	return label == "utf-8" || label == "utf8"
+}
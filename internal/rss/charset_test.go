@@ -0,0 +1,112 @@
+package rss
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func decodeTitle(t *testing.T, body []byte, contentType string) string {
+	t.Helper()
+
+	decoder, err := newXMLDecoder(body, contentType)
+	if err != nil {
+		t.Fatalf("newXMLDecoder(%q) returned error: %v", contentType, err)
+	}
+	var feed RSSFeed
+	if err := decoder.Decode(&feed); err != nil {
+		t.Fatalf("Decode with content type %q returned error: %v", contentType, err)
+	}
+	return feed.Channel.Title
+}
+
+func TestNewXMLDecoderCharsetPrecedence(t *testing.T) {
+	latin1Declared := func(title string) []byte {
+		return []byte(`<?xml version="1.0" encoding="ISO-8859-1"?><rss><channel><title>` + title + `</title></channel></rss>`)
+	}
+
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		want        string
+	}{
+		{
+			name:        "header utf-8 overrides latin1 declaration",
+			body:        latin1Declared("Café"),
+			contentType: "application/rss+xml; charset=utf-8",
+			want:        "Café",
+		},
+		{
+			name:        "header UTF8 spelling",
+			body:        latin1Declared("Café"),
+			contentType: "text/xml; charset=UTF8",
+			want:        "Café",
+		},
+		{
+			name:        "header latin1 overrides utf-8 declaration",
+			body:        []byte("<?xml version=\"1.0\" encoding=\"UTF-8\"?><rss><channel><title>Caf\xe9</title></channel></rss>"),
+			contentType: "application/rss+xml; charset=iso-8859-1",
+			want:        "Café",
+		},
+		{
+			name:        "declaration used without header charset",
+			body:        latin1Declared("Caf\xe9"),
+			contentType: "application/rss+xml",
+			want:        "Café",
+		},
+		{
+			name:        "declaration used without content type",
+			body:        latin1Declared("Caf\xe9"),
+			contentType: "",
+			want:        "Café",
+		},
+		{
+			name:        "windows-1252 header",
+			body:        []byte("<rss><channel><title>\x93Caf\xe9\x94</title></channel></rss>"),
+			contentType: "text/xml; charset=windows-1252",
+			want:        "“Café”",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeTitle(t, tt.body, tt.contentType); got != tt.want {
+				t.Errorf("title = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewXMLDecoderUnsupportedCharset(t *testing.T) {
+	_, err := newXMLDecoder([]byte("<rss/>"), "text/xml; charset=x-no-such-charset")
+	if err == nil {
+		t.Fatal("newXMLDecoder with an unknown charset returned no error")
+	}
+}
+
+func TestNewXMLDecoderLatin1Fixture(t *testing.T) {
+	body, err := os.ReadFile(filepath.Join("rsstest", "fixtures", "latin1.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoder, err := newXMLDecoder(body, "application/rss+xml")
+	if err != nil {
+		t.Fatalf("newXMLDecoder: %v", err)
+	}
+	var feed RSSFeed
+	if err := decoder.Decode(&feed); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	if got, want := feed.Channel.Title, "Actualités"; got != want {
+		t.Errorf("channel title = %q, want %q", got, want)
+	}
+	if len(feed.Channel.Item) != 1 {
+		t.Fatalf("got %d items, want 1", len(feed.Channel.Item))
+	}
+	if got, want := feed.Channel.Item[0].Title, "Café crème à Zürich"; got != want {
+		t.Errorf("item title = %q, want %q", got, want)
+	}
+}
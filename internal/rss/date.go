@@ -0,0 +1,165 @@
+package rss
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Feeds in the wild use many variations of RFC 822 and RFC 3339 dates:
+// missing weekdays, single digit days, two digit years, missing seconds,
+// named zones and fractional seconds. ParseDate normalizes the input and
+// then tries the layouts below in order.
+var rfc822Layouts = []string{
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04 -0700",
+	"2 Jan 06 15:04:05 -0700",
+	"2 Jan 06 15:04 -0700",
+	"2 January 2006 15:04:05 -0700",
+	"2 January 2006 15:04 -0700",
+	"2 Jan 2006 15:04:05",
+	"2 Jan 2006 15:04",
+	"2 January 2006 15:04:05",
+	"2 Jan 2006",
+	"2 January 2006",
+	// RFC 850, e.g. "Thursday, 05-Sep-24 15:04:05 GMT"
+	"2-Jan-06 15:04:05 -0700",
+	"2-Jan-2006 15:04:05 -0700",
+	// month first, e.g. "Jan 2, 2006 15:04:05 -0700"
+	"Jan 2 2006 15:04:05 -0700",
+	"Jan 2 2006 15:04:05",
+	"January 2 2006 15:04:05 -0700",
+	"Jan 2 2006",
+	"January 2 2006",
+}
+
+var isoLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999-0700",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999-0700",
+	"2006-01-02 15:04:05.999999999 -0700",
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+	"2006-01-02 15:04:05.999999999-0700 MST",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// Offsets of the zone names found in feeds. time.Parse only knows the
+// offset of a zone name when it matches the local zone, so names are
+// replaced by numeric offsets before parsing.
+var zoneOffsets = map[string]string{
+	"UT":   "+0000",
+	"UTC":  "+0000",
+	"GMT":  "+0000",
+	"Z":    "+0000",
+	"EST":  "-0500",
+	"EDT":  "-0400",
+	"CST":  "-0600",
+	"CDT":  "-0500",
+	"MST":  "-0700",
+	"MDT":  "-0600",
+	"PST":  "-0800",
+	"PDT":  "-0700",
+	"AKST": "-0900",
+	"AKDT": "-0800",
+	"HST":  "-1000",
+	"BST":  "+0100",
+	"IST":  "+0530",
+	"CET":  "+0100",
+	"CEST": "+0200",
+	"EET":  "+0200",
+	"EEST": "+0300",
+	"WET":  "+0000",
+	"WEST": "+0100",
+	"JST":  "+0900",
+	"KST":  "+0900",
+	"AEST": "+1000",
+	"AEDT": "+1100",
+	"NZST": "+1200",
+	"NZDT": "+1300",
+}
+
+var (
+	weekdayPrefix  = regexp.MustCompile(`(?i)^(mon|tue|wed|thu|fri|sat|sun)[a-z]*\.?(,\s*|\s+)`)
+	trailingZone   = regexp.MustCompile(`\s+([A-Za-z]{1,5})$`)
+	gmtOffset      = regexp.MustCompile(`\s+(?:GMT|UTC|UT)([+-]\d{2}:?\d{2})$`)
+	colonOffset    = regexp.MustCompile(`\s([+-]\d{2}):(\d{2})$`)
+	parenthetical  = regexp.MustCompile(`\s*\([^)]*\)$`)
+	isoDate        = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}`)
+	monthAliases   = regexp.MustCompile(`(?i)\b(sep)t\b\.?`)
+	whitespaceRuns = regexp.MustCompile(`\s+`)
+)
+
+// Parses the date of a feed item (RSS pubDate, Atom updated, Dublin Core
+// date) and returns it in UTC. Dates without a zone are assumed to be UTC.
+func ParseDate(value string) (time.Time, error) {
+	s := strings.TrimSpace(whitespaceRuns.ReplaceAllString(value, " "))
+	if s == "" {
+		return time.Time{}, fmt.Errorf("empty date")
+	}
+
+	if isoDate.MatchString(s) {
+		if t, ok := parseLayouts(isoLayouts, normalizeISO(s)); ok {
+			return t, nil
+		}
+	}
+
+	if t, ok := parseLayouts(rfc822Layouts, normalizeRFC822(s)); ok {
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("unable to parse date %q", value)
+}
+
+func parseLayouts(layouts []string, s string) (time.Time, bool) {
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+func normalizeISO(s string) string {
+	// a lowercase t or z is valid RFC 3339 but rejected by time.Parse
+	if len(s) > 10 && s[10] == 't' {
+		s = s[:10] + "T" + s[11:]
+	}
+	if strings.HasSuffix(s, "z") {
+		s = s[:len(s)-1] + "Z"
+	}
+	return s
+}
+
+func normalizeRFC822(s string) string {
+	s = parenthetical.ReplaceAllString(s, "")
+	s = weekdayPrefix.ReplaceAllString(s, "")
+	s = strings.ReplaceAll(s, ",", " ")
+	s = whitespaceRuns.ReplaceAllString(strings.TrimSpace(s), " ")
+	s = monthAliases.ReplaceAllString(s, "$1")
+
+	// "GMT+02:00" and friends
+	s = gmtOffset.ReplaceAllStringFunc(s, func(m string) string {
+		offset := gmtOffset.FindStringSubmatch(m)[1]
+		return " " + strings.ReplaceAll(offset, ":", "")
+	})
+
+	if m := trailingZone.FindStringSubmatch(s); m != nil {
+		if offset, ok := zoneOffsets[strings.ToUpper(m[1])]; ok {
+			s = s[:len(s)-len(m[0])] + " " + offset
+		} else if len(m[1]) == 1 {
+			// other single letter military zones are too ambiguous in
+			// practice, treat them like Z
+			s = s[:len(s)-len(m[0])] + " +0000"
+		}
+	}
+
+	// "+02:00" is not RFC 822 but common
+	s = colonOffset.ReplaceAllString(s, " $1$2")
+	return s
+}
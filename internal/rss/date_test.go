@@ -0,0 +1,113 @@
+package rss
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	utc := func(year int, month time.Month, day, hour, min, sec, nsec int) time.Time {
+		return time.Date(year, month, day, hour, min, sec, nsec, time.UTC)
+	}
+
+	tests := []struct {
+		input string
+		want  time.Time
+	}{
+		// RFC 822 / RFC 1123
+		{"Thu, 05 Sep 2024 14:30:00 +0000", utc(2024, 9, 5, 14, 30, 0, 0)},
+		{"Thu, 05 Sep 2024 14:30:00 -0400", utc(2024, 9, 5, 18, 30, 0, 0)},
+		{"Thu, 5 Sep 2024 14:30:00 +0200", utc(2024, 9, 5, 12, 30, 0, 0)},
+		{"Thu,5 Sep 2024 14:30:00 +0000", utc(2024, 9, 5, 14, 30, 0, 0)},
+		{"Thursday, 05 September 2024 14:30:00 +0000", utc(2024, 9, 5, 14, 30, 0, 0)},
+		{"05 Sep 2024 14:30:00 +0000", utc(2024, 9, 5, 14, 30, 0, 0)},
+		{"5 Sep 2024 14:30:00 +0000", utc(2024, 9, 5, 14, 30, 0, 0)},
+		{"Thu, 05 Sep 24 14:30:00 +0000", utc(2024, 9, 5, 14, 30, 0, 0)},
+		{"Thu, 05 Sep 2024 14:30 +0000", utc(2024, 9, 5, 14, 30, 0, 0)},
+		{"Thu, 05 Sep 24 14:30 +0000", utc(2024, 9, 5, 14, 30, 0, 0)},
+		{"Thu, 05 Sep 2024 14:30:00", utc(2024, 9, 5, 14, 30, 0, 0)},
+		{"Thu, 05 Sep 2024", utc(2024, 9, 5, 0, 0, 0, 0)},
+		{"  Thu,  05 Sep\t2024   14:30:00 +0000 ", utc(2024, 9, 5, 14, 30, 0, 0)},
+
+		// named zones
+		{"Thu, 05 Sep 2024 14:30:00 GMT", utc(2024, 9, 5, 14, 30, 0, 0)},
+		{"Thu, 05 Sep 2024 14:30:00 UT", utc(2024, 9, 5, 14, 30, 0, 0)},
+		{"Thu, 05 Sep 2024 14:30:00 UTC", utc(2024, 9, 5, 14, 30, 0, 0)},
+		{"Thu, 05 Sep 2024 14:30:00 Z", utc(2024, 9, 5, 14, 30, 0, 0)},
+		{"Thu, 05 Sep 2024 14:30:00 EST", utc(2024, 9, 5, 19, 30, 0, 0)},
+		{"Thu, 05 Sep 2024 14:30:00 EDT", utc(2024, 9, 5, 18, 30, 0, 0)},
+		{"Thu, 05 Sep 2024 14:30:00 PDT", utc(2024, 9, 5, 21, 30, 0, 0)},
+		{"Thu, 05 Sep 2024 14:30:00 CEST", utc(2024, 9, 5, 12, 30, 0, 0)},
+		{"Thu, 05 Sep 2024 14:30:00 IST", utc(2024, 9, 5, 9, 0, 0, 0)},
+		{"Thu, 05 Sep 2024 14:30:00 gmt", utc(2024, 9, 5, 14, 30, 0, 0)},
+		{"Thu, 05 Sep 2024 14:30 GMT", utc(2024, 9, 5, 14, 30, 0, 0)},
+		{"Thu, 05 Sep 2024 14:30:00 A", utc(2024, 9, 5, 14, 30, 0, 0)},
+
+		// offsets written in other ways
+		{"Thu, 05 Sep 2024 14:30:00 GMT+02:00", utc(2024, 9, 5, 12, 30, 0, 0)},
+		{"Thu, 05 Sep 2024 14:30:00 GMT-0500", utc(2024, 9, 5, 19, 30, 0, 0)},
+		{"Thu, 05 Sep 2024 14:30:00 UTC+01:00", utc(2024, 9, 5, 13, 30, 0, 0)},
+		{"Thu, 05 Sep 2024 14:30:00 +02:00", utc(2024, 9, 5, 12, 30, 0, 0)},
+		{"Thu, 05 Sep 2024 14:30:00 -0400 (EDT)", utc(2024, 9, 5, 18, 30, 0, 0)},
+		{"Thu, 05 Sep 2024 14:30:00 GMT (Coordinated Universal Time)", utc(2024, 9, 5, 14, 30, 0, 0)},
+
+		// RFC 850
+		{"Thursday, 05-Sep-24 14:30:00 GMT", utc(2024, 9, 5, 14, 30, 0, 0)},
+		{"Thursday, 5-Sep-2024 14:30:00 +0100", utc(2024, 9, 5, 13, 30, 0, 0)},
+
+		// month first and month aliases
+		{"Sep 5, 2024 14:30:00 +0000", utc(2024, 9, 5, 14, 30, 0, 0)},
+		{"September 5, 2024", utc(2024, 9, 5, 0, 0, 0, 0)},
+		{"Sept 5 2024", utc(2024, 9, 5, 0, 0, 0, 0)},
+		{"Sept. 5, 2024", utc(2024, 9, 5, 0, 0, 0, 0)},
+		{"Thu, 05 Sept 2024 14:30:00 +0000", utc(2024, 9, 5, 14, 30, 0, 0)},
+		{"5 Sept 2024", utc(2024, 9, 5, 0, 0, 0, 0)},
+		{"Thu, 05 September 2024 14:30:00 +0000", utc(2024, 9, 5, 14, 30, 0, 0)},
+
+		// RFC 3339 and ISO 8601
+		{"2024-09-05T14:30:00Z", utc(2024, 9, 5, 14, 30, 0, 0)},
+		{"2024-09-05T14:30:00+02:00", utc(2024, 9, 5, 12, 30, 0, 0)},
+		{"2024-09-05T14:30:00.123456789Z", utc(2024, 9, 5, 14, 30, 0, 123456789)},
+		{"2024-09-05T14:30:00.5-04:00", utc(2024, 9, 5, 18, 30, 0, 500000000)},
+		{"2024-09-05t14:30:00z", utc(2024, 9, 5, 14, 30, 0, 0)},
+		{"2024-09-05t14:30:00+00:00", utc(2024, 9, 5, 14, 30, 0, 0)},
+		{"2024-09-05T14:30:00+0200", utc(2024, 9, 5, 12, 30, 0, 0)},
+		{"2024-09-05T14:30:00", utc(2024, 9, 5, 14, 30, 0, 0)},
+		{"2024-09-05T14:30Z", utc(2024, 9, 5, 14, 30, 0, 0)},
+		{"2024-09-05T14:30", utc(2024, 9, 5, 14, 30, 0, 0)},
+		{"2024-09-05 14:30:00Z", utc(2024, 9, 5, 14, 30, 0, 0)},
+		{"2024-09-05 14:30:00 +0200", utc(2024, 9, 5, 12, 30, 0, 0)},
+		{"2024-09-05 14:30:00", utc(2024, 9, 5, 14, 30, 0, 0)},
+		{"2024-09-05", utc(2024, 9, 5, 0, 0, 0, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseDate(tt.input)
+			if err != nil {
+				t.Fatalf("ParseDate(%q) returned error: %v", tt.input, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseDate(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+			if got.Location() != time.UTC {
+				t.Errorf("ParseDate(%q) returned location %v, want UTC", tt.input, got.Location())
+			}
+		})
+	}
+}
+
+func TestParseDateInvalid(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"   ",
+		"yesterday",
+		"Thu, 32 Sep 2024 14:30:00 +0000",
+		"2024-13-05T14:30:00Z",
+		"Septembre 5 2024",
+	} {
+		if got, err := ParseDate(input); err == nil {
+			t.Errorf("ParseDate(%q) = %v, want an error", input, got)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package rss
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	TypeRSS  = "application/rss+xml"
+	TypeAtom = "application/atom+xml"
+)
+
+// Paths tried when a page does not advertise its feeds with link tags
+var commonFeedPaths = []string{"/feed", "/rss.xml", "/feed.xml", "/atom.xml", "/rss", "/index.xml"}
+
+// A feed found by Discover
+type Candidate struct {
+	URL   string
+	Title string
+	Type  string
+}
+
+// Finds the feeds for a url. When the url already points at a feed it is
+// returned as the only candidate. For an html page the feeds advertised by
+// <link rel="alternate"> tags are returned, falling back to probing common
+// feed paths on the same site.
+func (f *HTTPFetcher) Discover(pageUrl string) ([]Candidate, error) {
+	body, contentType, err := f.get(pageUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if feedType, ok := detectFeed(body, contentType); ok {
+		return []Candidate{{URL: pageUrl, Type: feedType}}, nil
+	}
+
+	base, err := url.Parse(pageUrl)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid url %v: %v", pageUrl, err)
+	}
+
+	candidates := parseFeedLinks(body, base)
+	if len(candidates) > 0 {
+		return candidates, nil
+	}
+
+	for _, path := range commonFeedPaths {
+		probe := base.ResolveReference(&url.URL{Path: path}).String()
+		body, contentType, err := f.get(probe, nil)
+		if err != nil {
+			continue
+		}
+		if feedType, ok := detectFeed(body, contentType); ok {
+			candidates = append(candidates, Candidate{URL: probe, Type: feedType})
+		}
+	}
+
+	return candidates, nil
+}
+
+// Discovers feeds with the default fetcher settings
+func Discover(pageUrl string) ([]Candidate, error) {
+	return defaultFetcher.Discover(pageUrl)
+}
+
+// Returns the first RSS candidate, the feed type FetchFeed can read
+func FirstRSS(candidates []Candidate) (Candidate, bool) {
+	for _, c := range candidates {
+		if c.Type == TypeRSS {
+			return c, true
+		}
+	}
+	return Candidate{}, false
+}
+
+// Reports whether a response is an RSS or Atom document, going by the
+// content type and, since many servers send feeds as text/xml or even
+// text/html, by the document's root element
+func detectFeed(body []byte, contentType string) (string, bool) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case TypeRSS:
+		return TypeRSS, true
+	case TypeAtom:
+		return TypeAtom, true
+	}
+
+	start := bytes.TrimSpace(body)
+	if len(start) > 1024 {
+		start = start[:1024]
+	}
+	switch {
+	case bytes.Contains(start, []byte("<rss")), bytes.Contains(start, []byte("<rdf:RDF")):
+		return TypeRSS, true
+	case bytes.Contains(start, []byte("<feed")):
+		return TypeAtom, true
+	}
+	return "", false
+}
+
+// Collects <link rel="alternate" type="application/rss+xml|atom+xml">
+// tags, resolving their hrefs against the page url
+func parseFeedLinks(body []byte, base *url.URL) []Candidate {
+	var candidates []Candidate
+	seen := make(map[string]bool)
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return candidates
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			if token.Data == "body" {
+				return candidates
+			}
+			if token.Data != "link" {
+				continue
+			}
+
+			attrs := make(map[string]string)
+			for _, attr := range token.Attr {
+				attrs[strings.ToLower(attr.Key)] = strings.TrimSpace(attr.Val)
+			}
+
+			feedType := strings.ToLower(attrs["type"])
+			if !hasToken(attrs["rel"], "alternate") || (feedType != TypeRSS && feedType != TypeAtom) {
+				continue
+			}
+
+			href, err := url.Parse(attrs["href"])
+			if err != nil || attrs["href"] == "" {
+				continue
+			}
+			feedUrl := base.ResolveReference(href).String()
+			if seen[feedUrl] {
+				continue
+			}
+			seen[feedUrl] = true
+
+			candidates = append(candidates, Candidate{
+				URL:   feedUrl,
+				Title: attrs["title"],
+				Type:  feedType,
+			})
+		}
+	}
+}
+
+// rel holds a space separated list of link types
+func hasToken(list string, token string) bool {
+	for _, t := range strings.Fields(strings.ToLower(list)) {
+		if t == token {
+			return true
+		}
+	}
+	return false
+}
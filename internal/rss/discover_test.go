@@ -0,0 +1,145 @@
+package rss_test
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/voylento/gator/internal/rss"
+	"github.com/voylento/gator/internal/rss/rsstest"
+)
+
+func TestFetchFeedBasic(t *testing.T) {
+	server := rsstest.NewServer()
+	defer server.Close()
+	server.HandleFixture("/feed.xml", rsstest.FeedBasic)
+
+	feed, err := rsstest.NewFetcher().FetchFeed(server.FeedURL("/feed.xml"))
+	if err != nil {
+		t.Fatalf("FetchFeed: %v", err)
+	}
+	if feed.Channel.Title == "" {
+		t.Error("channel title is empty")
+	}
+	if len(feed.Channel.Item) != 3 {
+		t.Fatalf("got %d items, want 3", len(feed.Channel.Item))
+	}
+	for _, item := range feed.Channel.Item {
+		if item.Title == "" || item.Link == "" {
+			t.Errorf("item %+v is missing its title or link", item)
+		}
+	}
+}
+
+// Atom feeds cannot be read yet; they are reported instead of being
+// returned as an empty RSS feed
+func TestFetchFeedAtom(t *testing.T) {
+	server := rsstest.NewServer()
+	defer server.Close()
+	server.HandleFixture("/atom.xml", rsstest.FeedAtom)
+	// served with a generic type, so only the root element gives it away
+	server.Handle("/atom-as-xml", rsstest.Response{
+		Header: http.Header{"Content-Type": {"text/xml"}},
+		Body:   rsstest.Fixture(rsstest.FeedAtom),
+	})
+
+	for _, path := range []string{"/atom.xml", "/atom-as-xml"} {
+		feed, err := rsstest.NewFetcher().FetchFeed(server.FeedURL(path))
+		if !errors.Is(err, rss.ErrNotRSS) {
+			t.Errorf("FetchFeed(%v) = %+v, %v, want ErrNotRSS", path, feed, err)
+		}
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	server := rsstest.NewServer()
+	defer server.Close()
+	server.HandleFixture("/", rsstest.PageWithFeeds)
+	server.HandleFixture("/feed.xml", rsstest.FeedBasic)
+	server.HandleFixture("/atom.xml", rsstest.FeedAtom)
+	server.Handle("/blog/", rsstest.Response{
+		Header: http.Header{"Content-Type": {"text/html"}},
+		Body: []byte(`<html><head>
+<link rel="alternate" type="application/atom+xml" href="atom.xml" title="Atom">
+<link rel="stylesheet" href="style.css">
+<link rel="Alternate Home" type="application/RSS+XML" href="../feed.xml?format=rss" title="RSS">
+<link rel="alternate" type="application/rss+xml" href="https://elsewhere.example/feed">
+<link rel="alternate" type="application/rss+xml" href="/feed.xml?format=rss">
+</head><body><link rel="alternate" type="application/rss+xml" href="/ignored.xml"></body></html>`),
+	})
+	server.Handle("/plain/", rsstest.Response{
+		Header: http.Header{"Content-Type": {"text/html"}},
+		Body:   []byte(`<html><head><title>No links</title></head><body></body></html>`),
+	})
+
+	tests := []struct {
+		name      string
+		path      string
+		want      []rss.Candidate
+		firstRSS  string
+		noFeedRSS bool
+	}{
+		{
+			name: "homepage with link tags",
+			path: "/",
+			want: []rss.Candidate{
+				{URL: server.FeedURL("/feed.xml"), Title: "Posts", Type: rss.TypeRSS},
+				{URL: server.FeedURL("/atom.xml"), Title: "Posts (Atom)", Type: rss.TypeAtom},
+			},
+			firstRSS: server.FeedURL("/feed.xml"),
+		},
+		{
+			name: "relative hrefs with Atom listed first",
+			path: "/blog/",
+			want: []rss.Candidate{
+				{URL: server.FeedURL("/blog/atom.xml"), Title: "Atom", Type: rss.TypeAtom},
+				{URL: server.FeedURL("/feed.xml?format=rss"), Title: "RSS", Type: rss.TypeRSS},
+				{URL: "https://elsewhere.example/feed", Type: rss.TypeRSS},
+			},
+			firstRSS: server.FeedURL("/feed.xml?format=rss"),
+		},
+		{
+			name: "feed url",
+			path: "/feed.xml",
+			want: []rss.Candidate{
+				{URL: server.FeedURL("/feed.xml"), Type: rss.TypeRSS},
+			},
+			firstRSS: server.FeedURL("/feed.xml"),
+		},
+		{
+			name: "atom feed url",
+			path: "/atom.xml",
+			want: []rss.Candidate{
+				{URL: server.FeedURL("/atom.xml"), Type: rss.TypeAtom},
+			},
+			noFeedRSS: true,
+		},
+		{
+			name: "page without links probes common paths",
+			path: "/plain/",
+			want: []rss.Candidate{
+				{URL: server.FeedURL("/feed.xml"), Type: rss.TypeRSS},
+				{URL: server.FeedURL("/atom.xml"), Type: rss.TypeAtom},
+			},
+			firstRSS: server.FeedURL("/feed.xml"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rsstest.NewFetcher().Discover(server.FeedURL(tt.path))
+			if err != nil {
+				t.Fatalf("Discover: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Discover(%v) =\n%+v\nwant\n%+v", tt.path, got, tt.want)
+			}
+
+			first, ok := rss.FirstRSS(got)
+			if ok == tt.noFeedRSS || first.URL != tt.firstRSS {
+				t.Errorf("FirstRSS = %v, %v, want %q", first.URL, ok, tt.firstRSS)
+			}
+		})
+	}
+}
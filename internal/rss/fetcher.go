@@ -0,0 +1,316 @@
+package rss
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	DefaultTimeout          = 10 * time.Second
+	DefaultUserAgent        = "gator"
+	DefaultMaxResponseBytes = 10 << 20
+)
+
+// Returned when a response body is larger than the fetcher's limit
+var ErrTooLarge = errors.New("response too large")
+
+// Returned by FetchFeed for documents that are not RSS, e.g. Atom feeds
+var ErrNotRSS = errors.New("not an RSS feed")
+
+// Settings for an HTTPFetcher. Zero values use the defaults.
+type FetcherOptions struct {
+	Timeout   time.Duration
+	UserAgent string
+
+	// Proxy url used for every request. When empty the HTTP_PROXY,
+	// HTTPS_PROXY and NO_PROXY environment variables apply.
+	Proxy string
+
+	// Largest response body accepted, counted after decompression so a
+	// small compressed body cannot expand without bound
+	MaxResponseBytes int64
+
+	// Tries per request for timeouts, dropped connections and 429/5xx
+	// responses; 1 disables retries
+	MaxAttempts int
+
+	// Wait before the first retry, doubled for each further retry up to
+	// MaxRetryDelay. Longer Retry-After waits make the fetch fail instead.
+	RetryDelay    time.Duration
+	MaxRetryDelay time.Duration
+
+	// Minimum time between two requests to the same host
+	HostInterval time.Duration
+}
+
+// Fetches feeds and web pages over a shared http client, so connections
+// to a host are reused across fetches. An HTTPFetcher is safe for
+// concurrent use.
+type HTTPFetcher struct {
+	client           *http.Client
+	userAgent        string
+	maxResponseBytes int64
+	maxAttempts      int
+	retryDelay       time.Duration
+	maxRetryDelay    time.Duration
+}
+
+var _ Fetcher = (*HTTPFetcher)(nil)
+
+var defaultFetcher, _ = NewHTTPFetcher(FetcherOptions{})
+
+func NewHTTPFetcher(opts FetcherOptions) (*HTTPFetcher, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.UserAgent == "" {
+		opts.UserAgent = DefaultUserAgent
+	}
+	if opts.MaxResponseBytes <= 0 {
+		opts.MaxResponseBytes = DefaultMaxResponseBytes
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = DefaultRetryDelay
+	}
+	if opts.MaxRetryDelay <= 0 {
+		opts.MaxRetryDelay = DefaultMaxRetryDelay
+	}
+	if opts.HostInterval <= 0 {
+		opts.HostInterval = DefaultHostInterval
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if opts.Proxy != "" {
+		proxyUrl, err := url.Parse(opts.Proxy)
+		if err != nil || proxyUrl.Scheme == "" || proxyUrl.Host == "" {
+			return nil, fmt.Errorf("Invalid proxy url %q", opts.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxyUrl)
+	}
+	// responses are decompressed by decodeBody, which also handles
+	// deflate and brotli
+	transport.DisableCompression = true
+	transport.MaxIdleConnsPerHost = 4
+
+	return &HTTPFetcher{
+		client: &http.Client{
+			Transport: &limitedTransport{
+				limiter: newHostLimiter(opts.HostInterval),
+				next:    transport,
+			},
+			Timeout:       opts.Timeout,
+			CheckRedirect: checkRedirect,
+		},
+		userAgent:        opts.UserAgent,
+		maxResponseBytes: opts.MaxResponseBytes,
+		maxAttempts:      opts.MaxAttempts,
+		retryDelay:       opts.RetryDelay,
+		maxRetryDelay:    opts.MaxRetryDelay,
+	}, nil
+}
+
+// Fetches and parses an RSS feed
+func (f *HTTPFetcher) FetchFeed(feedUrl string) (*RSSFeed, error) {
+	trace := &redirectTrace{permanent: true}
+	body, contentType, err := f.get(feedUrl, trace)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder, err := newXMLDecoder(body, contentType)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding response body: %v", err)
+	}
+
+	// any root element decodes without error, so check it is <rss> (or
+	// RSS 1.0's <rdf:RDF>) rather than reporting an Atom feed as empty
+	var doc struct {
+		XMLName xml.Name
+		RSSFeed
+	}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("Error unmarshalling response body: %v", err)
+	}
+	if doc.XMLName.Local != "rss" && doc.XMLName.Local != "RDF" {
+		return nil, fmt.Errorf("%w: %v is a <%v> document", ErrNotRSS, feedUrl, doc.XMLName.Local)
+	}
+	rss := doc.RSSFeed
+
+	if trace.movedTo != feedUrl {
+		rss.MovedTo = trace.movedTo
+	}
+
+	return &rss, nil
+}
+
+// Returns the decompressed body and content type of a url, retrying
+// transient failures. trace may be nil when the redirects are of no
+// interest.
+func (f *HTTPFetcher) get(pageUrl string, trace *redirectTrace) ([]byte, string, error) {
+	ctx := context.Background()
+
+	var body []byte
+	var contentType string
+	err := f.withRetry(ctx, func() error {
+		// each attempt follows the redirects afresh
+		var attemptTrace *redirectTrace
+		if trace != nil {
+			attemptTrace = &redirectTrace{permanent: true}
+		}
+
+		var err error
+		body, contentType, err = f.getOnce(ctx, pageUrl, attemptTrace)
+		if err == nil && trace != nil {
+			*trace = *attemptTrace
+		}
+		return err
+	})
+	return body, contentType, err
+}
+
+func (f *HTTPFetcher) getOnce(ctx context.Context, pageUrl string, trace *redirectTrace) ([]byte, string, error) {
+	if trace != nil {
+		ctx = context.WithValue(ctx, redirectTraceKey{}, trace)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageUrl, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("Error creating request: %v", err)
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		err = fmt.Errorf("Error making request: %w", err)
+		if retryableNetError(err) {
+			return nil, "", &retryableError{err: err}
+		}
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusGone {
+		return nil, "", fmt.Errorf("%w: %v", ErrGone, pageUrl)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("Request error: %v", resp.StatusCode)
+		if retryableStatus(resp.StatusCode) {
+			var after time.Duration
+			if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+				after = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+			}
+			return nil, "", &retryableError{err: err, after: after}
+		}
+		return nil, "", err
+	}
+
+	reader, err := decodeBody(resp.Body, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		return nil, "", fmt.Errorf("Error decompressing response body: %v", err)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(reader, f.maxResponseBytes+1))
+	if err != nil {
+		err = fmt.Errorf("Error reading response body: %w", err)
+		if retryableNetError(err) {
+			return nil, "", &retryableError{err: err}
+		}
+		return nil, "", err
+	}
+	if int64(len(body)) > f.maxResponseBytes {
+		return nil, "", fmt.Errorf("%w: %v is larger than %d bytes", ErrTooLarge, pageUrl, f.maxResponseBytes)
+	}
+
+	return body, resp.Header.Get("Content-Type"), nil
+}
+
+// Undoes the Content-Encoding of a response. Encodings are listed in the
+// order they were applied, so they are removed last to first.
+func decodeBody(body io.Reader, contentEncoding string) (io.Reader, error) {
+	encodings := strings.Split(contentEncoding, ",")
+	reader := body
+	for i := len(encodings) - 1; i >= 0; i-- {
+		var err error
+		switch encoding := strings.ToLower(strings.TrimSpace(encodings[i])); encoding {
+		case "", "identity":
+		case "gzip", "x-gzip":
+			reader, err = gzip.NewReader(reader)
+		case "deflate":
+			reader, err = newDeflateReader(reader)
+		case "br":
+			reader = brotli.NewReader(reader)
+		default:
+			return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return reader, nil
+}
+
+// HTTP deflate is meant to be zlib wrapped, but some servers send a raw
+// deflate stream, so the zlib header is checked before choosing a reader
+func newDeflateReader(body io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(body)
+	header, err := buffered.Peek(2)
+	if err != nil && len(header) < 2 {
+		return flate.NewReader(buffered), nil
+	}
+
+	isZlib := header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0
+	if isZlib {
+		return zlib.NewReader(buffered)
+	}
+	return flate.NewReader(buffered), nil
+}
+
+// Records where a request ended up when every redirect on the way was
+// permanent (301/308)
+type redirectTrace struct {
+	permanent bool
+	movedTo   string
+}
+
+type redirectTraceKey struct{}
+
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+
+	trace, ok := req.Context().Value(redirectTraceKey{}).(*redirectTrace)
+	if !ok {
+		return nil
+	}
+
+	// req.Response is the redirect response that led to req
+	status := req.Response.StatusCode
+	if trace.permanent && (status == http.StatusMovedPermanently || status == http.StatusPermanentRedirect) {
+		trace.movedTo = req.URL.String()
+	} else {
+		// a temporary hop anywhere in the chain means the feed has not moved
+		trace.permanent = false
+		trace.movedTo = ""
+	}
+	return nil
+}
@@ -0,0 +1,347 @@
+package rss_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/voylento/gator/internal/rss"
+	"github.com/voylento/gator/internal/rss/rsstest"
+)
+
+func redirect(status int, location string) rsstest.Response {
+	return rsstest.Response{
+		Status: status,
+		Header: http.Header{"Location": {location}},
+	}
+}
+
+func TestFetchFeedMovedTo(t *testing.T) {
+	tests := []struct {
+		name  string
+		chain []int // statuses of the redirects from /hop0 on
+		moved bool  // whether MovedTo is the final url
+	}{
+		{"no redirect", nil, false},
+		{"301", []int{301}, true},
+		{"308", []int{308}, true},
+		{"301 then 301", []int{301, 301}, true},
+		{"302", []int{302}, false},
+		{"307", []int{307}, false},
+		{"301 then 302", []int{301, 302}, false},
+		{"302 then 301", []int{302, 301}, false},
+		{"301 then 307 then 301", []int{301, 307, 301}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := rsstest.NewServer()
+			defer server.Close()
+
+			paths := []string{"/hop0", "/hop1", "/hop2", "/hop3"}
+			for i, status := range tt.chain {
+				server.Handle(paths[i], redirect(status, server.FeedURL(paths[i+1])))
+			}
+			final := paths[len(tt.chain)]
+			server.HandleFixture(final, rsstest.FeedBasic)
+
+			feed, err := rsstest.NewFetcher().FetchFeed(server.FeedURL(paths[0]))
+			if err != nil {
+				t.Fatalf("FetchFeed: %v", err)
+			}
+
+			want := ""
+			if tt.moved {
+				want = server.FeedURL(final)
+			}
+			if feed.MovedTo != want {
+				t.Errorf("MovedTo = %q, want %q", feed.MovedTo, want)
+			}
+		})
+	}
+}
+
+// Compresses data with a single Content-Encoding
+func encode(t *testing.T, encoding string, data []byte) []byte {
+	t.Helper()
+
+	var b bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&b)
+	case "zlib":
+		w = zlib.NewWriter(&b)
+	case "raw deflate":
+		var err error
+		w, err = flate.NewWriter(&b, flate.DefaultCompression)
+		if err != nil {
+			t.Fatal(err)
+		}
+	case "br":
+		w = brotli.NewWriter(&b)
+	default:
+		t.Fatalf("unknown encoding %q", encoding)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestFetchFeedContentEncoding(t *testing.T) {
+	plain := rsstest.Fixture(rsstest.FeedBasic)
+
+	tests := []struct {
+		name   string
+		header string
+		body   []byte
+	}{
+		{"identity", "", plain},
+		{"gzip", "gzip", encode(t, "gzip", plain)},
+		{"x-gzip", "x-gzip", encode(t, "gzip", plain)},
+		{"zlib deflate", "deflate", encode(t, "zlib", plain)},
+		{"raw deflate", "deflate", encode(t, "raw deflate", plain)},
+		{"brotli", "br", encode(t, "br", plain)},
+		{"gzip then brotli", "gzip, br", encode(t, "br", encode(t, "gzip", plain))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := rsstest.NewServer()
+			defer server.Close()
+
+			header := http.Header{"Content-Type": {"application/rss+xml"}}
+			if tt.header != "" {
+				header.Set("Content-Encoding", tt.header)
+			}
+			server.Handle("/feed.xml", rsstest.Response{Header: header, Body: tt.body})
+
+			feed, err := rsstest.NewFetcher().FetchFeed(server.FeedURL("/feed.xml"))
+			if err != nil {
+				t.Fatalf("FetchFeed: %v", err)
+			}
+			if len(feed.Channel.Item) != 3 {
+				t.Errorf("got %d items, want 3", len(feed.Channel.Item))
+			}
+		})
+	}
+}
+
+func TestFetchFeedUnsupportedEncoding(t *testing.T) {
+	server := rsstest.NewServer()
+	defer server.Close()
+	server.Handle("/feed.xml", rsstest.Response{
+		Header: http.Header{"Content-Encoding": {"zstd"}},
+		Body:   rsstest.Fixture(rsstest.FeedBasic),
+	})
+
+	if _, err := rsstest.NewFetcher().FetchFeed(server.FeedURL("/feed.xml")); err == nil {
+		t.Fatal("FetchFeed with an unsupported encoding succeeded")
+	}
+}
+
+// The limit applies to the decompressed body, so a small compressed
+// response cannot expand without bound
+func TestFetchFeedMaxResponseBytes(t *testing.T) {
+	const limit = 4096
+	padding := strings.Repeat(" ", 64*limit)
+	large := []byte(`<rss><channel><title>Large</title><description>` + padding + `</description></channel></rss>`)
+	small := []byte(`<rss><channel><title>Small</title></channel></rss>`)
+
+	fetcher, err := rss.NewHTTPFetcher(rss.FetcherOptions{
+		MaxResponseBytes: limit,
+		MaxAttempts:      1,
+		HostInterval:     time.Nanosecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		tooLarge bool
+	}{
+		{"plain over limit", "", large, true},
+		{"gzip over limit", "gzip", encode(t, "gzip", large), true},
+		{"brotli over limit", "br", encode(t, "br", large), true},
+		{"gzip under limit", "gzip", encode(t, "gzip", small), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.encoding != "" && len(tt.body) >= limit {
+				t.Fatalf("compressed body is %d bytes, the test needs it under the limit", len(tt.body))
+			}
+
+			server := rsstest.NewServer()
+			defer server.Close()
+			header := http.Header{"Content-Type": {"application/rss+xml"}}
+			if tt.encoding != "" {
+				header.Set("Content-Encoding", tt.encoding)
+			}
+			server.Handle("/feed.xml", rsstest.Response{Header: header, Body: tt.body})
+
+			_, err := fetcher.FetchFeed(server.FeedURL("/feed.xml"))
+			if tt.tooLarge && !errors.Is(err, rss.ErrTooLarge) {
+				t.Errorf("FetchFeed returned %v, want ErrTooLarge", err)
+			}
+			if !tt.tooLarge && err != nil {
+				t.Errorf("FetchFeed: %v", err)
+			}
+		})
+	}
+}
+
+// Answers the first len(statuses) requests with those statuses and
+// headers, and then serves the basic fixture
+func newFlakyServer(t *testing.T, statuses []int, header http.Header) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(hits.Add(1))
+		if n <= len(statuses) {
+			for key, values := range header {
+				w.Header()[key] = values
+			}
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write(rsstest.Fixture(rsstest.FeedBasic))
+	}))
+	t.Cleanup(server.Close)
+	return server, &hits
+}
+
+func newRetryFetcher(t *testing.T, opts rss.FetcherOptions) *rss.HTTPFetcher {
+	t.Helper()
+
+	if opts.RetryDelay == 0 {
+		opts.RetryDelay = time.Millisecond
+	}
+	if opts.MaxRetryDelay == 0 {
+		opts.MaxRetryDelay = 10 * time.Millisecond
+	}
+	if opts.HostInterval == 0 {
+		opts.HostInterval = time.Nanosecond
+	}
+	fetcher, err := rss.NewHTTPFetcher(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fetcher
+}
+
+func TestFetchFeedRetryAfter(t *testing.T) {
+	server, hits := newFlakyServer(t, []int{503}, http.Header{"Retry-After": {"1"}})
+	fetcher := newRetryFetcher(t, rss.FetcherOptions{MaxRetryDelay: 2 * time.Second})
+
+	start := time.Now()
+	if _, err := fetcher.FetchFeed(server.URL); err != nil {
+		t.Fatalf("FetchFeed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, want the 1s asked for by Retry-After", elapsed)
+	}
+	if got := hits.Load(); got != 2 {
+		t.Errorf("server hit %d times, want 2", got)
+	}
+}
+
+func TestFetchFeedRetryAfterTooLong(t *testing.T) {
+	server, hits := newFlakyServer(t, []int{503}, http.Header{"Retry-After": {"3600"}})
+	fetcher := newRetryFetcher(t, rss.FetcherOptions{MaxRetryDelay: time.Second})
+
+	_, err := fetcher.FetchFeed(server.URL)
+	if err == nil || !strings.Contains(err.Error(), "retry after 1h0m0s") {
+		t.Errorf("FetchFeed returned %v, want a failure naming the Retry-After wait", err)
+	}
+	if got := hits.Load(); got != 1 {
+		t.Errorf("server hit %d times, want 1", got)
+	}
+}
+
+func TestFetchFeedRetriesTooManyRequests(t *testing.T) {
+	server, hits := newFlakyServer(t, []int{429, 429}, nil)
+	fetcher := newRetryFetcher(t, rss.FetcherOptions{MaxAttempts: 3})
+
+	feed, err := fetcher.FetchFeed(server.URL)
+	if err != nil {
+		t.Fatalf("FetchFeed: %v", err)
+	}
+	if len(feed.Channel.Item) != 3 {
+		t.Errorf("got %d items, want 3", len(feed.Channel.Item))
+	}
+	if got := hits.Load(); got != 3 {
+		t.Errorf("server hit %d times, want 3", got)
+	}
+}
+
+func TestFetchFeedGivesUp(t *testing.T) {
+	server, hits := newFlakyServer(t, []int{500, 502, 504, 500}, nil)
+	fetcher := newRetryFetcher(t, rss.FetcherOptions{MaxAttempts: 3})
+
+	_, err := fetcher.FetchFeed(server.URL)
+	if err == nil || !strings.Contains(err.Error(), "gave up after 3 attempts") {
+		t.Errorf("FetchFeed returned %v, want it to give up after 3 attempts", err)
+	}
+	if got := hits.Load(); got != 3 {
+		t.Errorf("server hit %d times, want 3", got)
+	}
+}
+
+func TestFetchFeedDoesNotRetryPermanentFailures(t *testing.T) {
+	server, hits := newFlakyServer(t, []int{404, 404}, nil)
+	fetcher := newRetryFetcher(t, rss.FetcherOptions{MaxAttempts: 3})
+
+	if _, err := fetcher.FetchFeed(server.URL); err == nil {
+		t.Fatal("FetchFeed of a 404 succeeded")
+	}
+	if got := hits.Load(); got != 1 {
+		t.Errorf("server hit %d times, want 1", got)
+	}
+
+	gone := rsstest.NewServer()
+	defer gone.Close()
+	gone.Fail("/feed.xml", 410)
+	_, err := fetcher.FetchFeed(gone.FeedURL("/feed.xml"))
+	if !errors.Is(err, rss.ErrGone) {
+		t.Errorf("FetchFeed of a 410 returned %v, want ErrGone", err)
+	}
+}
+
+func TestFetchFeedSpacesRequestsToAHost(t *testing.T) {
+	const interval = 50 * time.Millisecond
+	server, hits := newFlakyServer(t, nil, nil)
+	fetcher := newRetryFetcher(t, rss.FetcherOptions{HostInterval: interval})
+
+	start := time.Now()
+	for range 3 {
+		if _, err := fetcher.FetchFeed(server.URL); err != nil {
+			t.Fatalf("FetchFeed: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 2*interval {
+		t.Errorf("three fetches from one host took %v, want at least %v", elapsed, 2*interval)
+	}
+	if got := hits.Load(); got != 3 {
+		t.Errorf("server hit %d times, want 3", got)
+	}
+}
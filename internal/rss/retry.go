@@ -0,0 +1,192 @@
+package rss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	DefaultMaxAttempts   = 3
+	DefaultRetryDelay    = time.Second
+	DefaultMaxRetryDelay = time.Minute
+	DefaultHostInterval  = 500 * time.Millisecond
+)
+
+// A failed attempt that is worth repeating: a network error, a timeout or
+// a 429/5xx response. after is the wait asked for by a Retry-After header.
+type retryableError struct {
+	err   error
+	after time.Duration
+}
+
+func (e *retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryableError) Unwrap() error {
+	return e.err
+}
+
+// Statuses that usually clear up by themselves
+func retryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Timeouts and connections dropped by the server are retried. Failures
+// that another attempt will not fix, such as an unknown host, a refused
+// connection, a malformed url or too many redirects, are not.
+func retryableNetError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
+
+// Reads a Retry-After header, which holds either a number of seconds or
+// an HTTP date. Returns 0 when the header is missing or invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0)
+	}
+	return 0
+}
+
+// Runs attempt until it succeeds, fails with an error that is not
+// retryable, or runs out of attempts. Waits grow exponentially from
+// retryDelay with jitter so that many clients failing together do not retry
+// in lockstep. A Retry-After wait longer than maxRetryDelay is not waited
+// out; the fetch fails instead and is picked up again on a later run.
+func (f *HTTPFetcher) withRetry(ctx context.Context, attempt func() error) error {
+	var err error
+	for i := 1; ; i++ {
+		err = attempt()
+
+		var retryable *retryableError
+		if !errors.As(err, &retryable) {
+			return err
+		}
+		if i >= f.maxAttempts {
+			break
+		}
+
+		wait := f.backoff(i)
+		if retryable.after > 0 {
+			if retryable.after > f.maxRetryDelay {
+				return fmt.Errorf("%w (server asked to retry after %v)", err, retryable.after)
+			}
+			wait = retryable.after
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+
+	if f.maxAttempts > 1 {
+		return fmt.Errorf("%w (gave up after %d attempts)", err, f.maxAttempts)
+	}
+	return err
+}
+
+// The wait before retry n (1-based): retryDelay doubled for every earlier
+// retry, capped at maxRetryDelay, with up to half of it taken off at random
+func (f *HTTPFetcher) backoff(n int) time.Duration {
+	wait := f.retryDelay
+	for i := 1; i < n && wait < f.maxRetryDelay; i++ {
+		wait *= 2
+	}
+	wait = min(wait, f.maxRetryDelay)
+
+	half := int64(wait / 2)
+	if half <= 0 {
+		return wait
+	}
+	return time.Duration(half + rand.Int64N(half+1))
+}
+
+// Spaces out requests to the same host, including redirects and retries,
+// so that a publisher with many feeds is not hammered
+type hostLimiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next map[string]time.Time
+}
+
+func newHostLimiter(interval time.Duration) *hostLimiter {
+	return &hostLimiter{
+		interval: interval,
+		next:     make(map[string]time.Time),
+	}
+}
+
+// Blocks until a request to host may be sent and reserves that slot
+func (l *hostLimiter) wait(ctx context.Context, host string) error {
+	l.mu.Lock()
+	now := time.Now()
+	slot := l.next[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next[host] = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	if delay := slot.Sub(now); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return nil
+}
+
+// An http.RoundTripper that waits for the host limiter before each request
+type limitedTransport struct {
+	limiter *hostLimiter
+	next    http.RoundTripper
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.wait(req.Context(), req.URL.Host); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,178 @@
+package rss
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 10, 5, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"0", 0},
+		{"5", 5 * time.Second},
+		{"120", 2 * time.Minute},
+		{"-3", 0},
+		{"soon", 0},
+		{"Mon, 05 Oct 2026 09:00:30 GMT", 30 * time.Second},
+		{"Mon, 05 Oct 2026 08:59:00 GMT", 0},
+		{"Monday, 05-Oct-26 09:01:00 GMT", time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	f := &HTTPFetcher{retryDelay: time.Second, maxRetryDelay: 5 * time.Second}
+
+	tests := []struct {
+		n    int
+		full time.Duration // the wait before jitter
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 5 * time.Second},
+		{10, 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		for range 20 {
+			got := f.backoff(tt.n)
+			if got < tt.full/2 || got > tt.full {
+				t.Errorf("backoff(%d) = %v, want between %v and %v", tt.n, got, tt.full/2, tt.full)
+			}
+		}
+	}
+}
+
+func TestRetryableNetError(t *testing.T) {
+	opError := func(err error) error {
+		return &url.Error{Op: "Get", URL: "https://example.com/feed.xml", Err: &net.OpError{Op: "read", Net: "tcp", Err: err}}
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"timeout", &net.DNSError{Err: "i/o timeout", IsTimeout: true}, true},
+		{"deadline", opError(os.ErrDeadlineExceeded), true},
+		{"connection reset", opError(os.NewSyscallError("read", syscall.ECONNRESET)), true},
+		{"broken pipe", opError(os.NewSyscallError("write", syscall.EPIPE)), true},
+		{"server closed connection", fmt.Errorf("Error making request: %w", &url.Error{Op: "Get", Err: io.EOF}), true},
+		{"truncated body", fmt.Errorf("Error reading response body: %w", io.ErrUnexpectedEOF), true},
+		{"no such host", opError(&net.DNSError{Err: "no such host", Name: "nowhere.invalid", IsNotFound: true}), false},
+		{"connection refused", opError(os.NewSyscallError("connect", syscall.ECONNREFUSED)), false},
+		{"other error", errors.New("stopped after 10 redirects"), false},
+	}
+
+	for _, tt := range tests {
+		if got := retryableNetError(tt.err); got != tt.want {
+			t.Errorf("%v: retryableNetError(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRetryableNetErrorRefusedConnection(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	_, err = net.Dial("tcp", addr)
+	if err == nil {
+		t.Skip("connection to a closed port succeeded")
+	}
+	if retryableNetError(err) {
+		t.Errorf("refused connection %v is retried", err)
+	}
+}
+
+func TestWithRetryKeepsCause(t *testing.T) {
+	cause := errors.New("server error")
+	f := &HTTPFetcher{maxAttempts: 3, retryDelay: time.Millisecond, maxRetryDelay: 10 * time.Millisecond}
+
+	attempts := 0
+	err := f.withRetry(context.Background(), func() error {
+		attempts++
+		return &retryableError{err: cause}
+	})
+	if attempts != 3 {
+		t.Errorf("made %d attempts, want 3", attempts)
+	}
+	if !errors.Is(err, cause) {
+		t.Errorf("error after giving up %v does not wrap the cause", err)
+	}
+
+	attempts = 0
+	err = f.withRetry(context.Background(), func() error {
+		attempts++
+		return &retryableError{err: cause, after: time.Hour}
+	})
+	if attempts != 1 {
+		t.Errorf("made %d attempts with a long Retry-After, want 1", attempts)
+	}
+	if !errors.Is(err, cause) {
+		t.Errorf("error for a long Retry-After %v does not wrap the cause", err)
+	}
+
+	// errors that are not retryable are returned as they are
+	err = f.withRetry(context.Background(), func() error {
+		return fmt.Errorf("%w: https://example.com/feed.xml", ErrGone)
+	})
+	if !errors.Is(err, ErrGone) {
+		t.Errorf("withRetry returned %v, want ErrGone", err)
+	}
+}
+
+func TestHostLimiter(t *testing.T) {
+	const interval = 50 * time.Millisecond
+	limiter := newHostLimiter(interval)
+	ctx := context.Background()
+
+	start := time.Now()
+	for range 3 {
+		if err := limiter.wait(ctx, "example.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 2*interval {
+		t.Errorf("three requests to one host took %v, want at least %v", elapsed, 2*interval)
+	}
+
+	// other hosts are not held up
+	start = time.Now()
+	if err := limiter.wait(ctx, "example.org"); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed >= interval {
+		t.Errorf("request to another host waited %v", elapsed)
+	}
+
+	// a cancelled request gives up its wait
+	limiter.wait(ctx, "example.net")
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := limiter.wait(cancelled, "example.net"); !errors.Is(err, context.Canceled) {
+		t.Errorf("wait with a cancelled context returned %v", err)
+	}
+}
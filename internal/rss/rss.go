@@ -1,15 +1,20 @@
 package rss
 
 import (
-	"context"
-	"encoding/xml"
-	"io"
-	"log"
-	"net/http"
-	"time"
+	"errors"
 )
 
+// Returned by FetchFeed when the publisher reports the feed as 410 Gone
+var ErrGone = errors.New("feed is gone")
+
+const maxRedirects = 10
+
 type RSSFeed struct {
+	// The url the feed was fetched from when every redirect on the way was
+	// permanent (301/308), i.e. where the feed now lives. Empty when the
+	// feed was not redirected or a redirect was temporary.
+	MovedTo string `xml:"-"`
+
 	Channel struct {
 		Title       string    `xml:"title"`
 		Link        string    `xml:"link"`
@@ -25,38 +30,18 @@ type RSSItem struct {
 	PubDate     string `xml:"pubDate"`
 }
 
-func FetchFeed(feedUrl string) (*RSSFeed, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedUrl, nil)
-	if err != nil {
-		log.Fatalf("Error creating request: %v", err)
-	}
-
-	req.Header.Set("User-Agent", "gator")
+// A source of feeds. HTTPFetcher reads them from the web; other
+// implementations can serve fixtures or alternate sources, so code such as
+// the aggregator can run without the internet.
+type Fetcher interface {
+	// Fetches and parses the feed at a url
+	FetchFeed(feedUrl string) (*RSSFeed, error)
 
-	client := &http.Client{}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Fatalf("Error making request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		log.Fatalf("Request error: %v", resp.StatusCode)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Fatalf("Error reading response body: %v", err)
-	}
-
-	var rss RSSFeed
-	if err := xml.Unmarshal(body, &rss); err != nil {
-		log.Fatalf("Error unmarshalling response body: %v", err)
-	}
+	// Finds the feeds a url points to or advertises
+	Discover(pageUrl string) ([]Candidate, error)
+}
 
-	return &rss, nil
+// Fetches a feed with the default fetcher settings
+func FetchFeed(feedUrl string) (*RSSFeed, error) {
+	return defaultFetcher.FetchFeed(feedUrl)
 }
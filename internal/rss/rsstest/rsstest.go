@@ -0,0 +1,145 @@
+// Package rsstest serves fixture feeds from a local httptest server, so
+// feed fetching and the aggregator can be exercised without the internet.
+package rsstest
+
+import (
+	"embed"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/voylento/gator/internal/rss"
+)
+
+// The bundled fixtures
+const (
+	// A well-formed RSS feed with three posts, one of them undated
+	FeedBasic = "basic.xml"
+	// Items missing a title, link or description
+	FeedBlankFields = "blank-fields.xml"
+	// The same link listed twice
+	FeedDuplicates = "duplicates.xml"
+	// An RSS feed encoded in ISO-8859-1
+	FeedLatin1 = "latin1.xml"
+	// An Atom feed
+	FeedAtom = "atom.xml"
+	// A homepage advertising /feed.xml and /atom.xml with link tags
+	PageWithFeeds = "site.html"
+)
+
+//go:embed fixtures
+var fixtures embed.FS
+
+// Returns the contents of a bundled fixture. Panics when there is no
+// fixture of that name.
+func Fixture(name string) []byte {
+	data, err := fixtures.ReadFile(path.Join("fixtures", name))
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// A canned response. A zero Status means 200 OK.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// An httptest server answering each path with a canned response. Paths
+// without a response get 404 Not Found.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	responses map[string]Response
+	hits      map[string]int
+}
+
+// Starts a server; call Close when done
+func NewServer() *Server {
+	s := &Server{
+		responses: make(map[string]Response),
+		hits:      make(map[string]int),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Answers requests for a path with resp
+func (s *Server) Handle(path string, resp Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[path] = resp
+}
+
+// Serves a bundled fixture at a path with a matching content type
+func (s *Server) HandleFixture(path string, name string) {
+	contentType := "application/rss+xml"
+	switch name {
+	case FeedAtom:
+		contentType = "application/atom+xml"
+	case PageWithFeeds:
+		contentType = "text/html; charset=utf-8"
+	}
+
+	s.Handle(path, Response{
+		Header: http.Header{"Content-Type": {contentType}},
+		Body:   Fixture(name),
+	})
+}
+
+// Answers requests for a path with an error status and an empty body
+func (s *Server) Fail(path string, status int) {
+	s.Handle(path, Response{Status: status})
+}
+
+// Returns the absolute url of a path on the server
+func (s *Server) FeedURL(path string) string {
+	return s.URL + path
+}
+
+// Returns how many requests were made for a path
+func (s *Server) Hits(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hits[path]
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.hits[r.URL.Path]++
+	resp, ok := s.responses[r.URL.Path]
+	s.mu.Unlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	for key, values := range resp.Header {
+		w.Header()[key] = values
+	}
+	if resp.Status != 0 {
+		w.WriteHeader(resp.Status)
+	}
+	w.Write(resp.Body)
+}
+
+// Returns an HTTPFetcher that retries immediately and does not space out
+// requests, so failures are reported without the production delays
+func NewFetcher() *rss.HTTPFetcher {
+	fetcher, err := rss.NewHTTPFetcher(rss.FetcherOptions{
+		Timeout:       5 * time.Second,
+		RetryDelay:    time.Millisecond,
+		MaxRetryDelay: 10 * time.Millisecond,
+		HostInterval:  time.Nanosecond,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return fetcher
+}
@@ -0,0 +1,151 @@
+// Package storage opens the database a db_url points to. Postgres urls
+// and connection strings use lib/pq; sqlite: urls use a SQLite file, for
+// personal use without a database server.
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/voylento/gator/internal/database"
+	"github.com/voylento/gator/internal/database/sqlitedb"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+type Backend string
+
+const (
+	Postgres Backend = "postgres"
+	SQLite   Backend = "sqlite"
+)
+
+// Pragmas applied to every SQLite connection: foreign keys for the ON
+// DELETE CASCADE rules, WAL and a busy timeout so that agg and other
+// commands can use the file at the same time
+var sqlitePragmas = []string{"foreign_keys(1)", "journal_mode(WAL)", "busy_timeout(5000)"}
+
+// An open database and the backend it uses
+type DB struct {
+	*sql.DB
+	Backend Backend
+}
+
+// Returns the backend for a db_url, going by its scheme
+func BackendFor(dbUrl string) Backend {
+	scheme, _, found := strings.Cut(dbUrl, ":")
+	if found && (scheme == "sqlite" || scheme == "sqlite3") {
+		return SQLite
+	}
+	return Postgres
+}
+
+// Opens the database for a db_url. sqlite:<path> urls (sqlite:gator.db,
+// sqlite:///home/me/gator.db or sqlite:~/gator.db) open the SQLite file at
+// path, creating it and its directory when missing; anything else is a
+// Postgres url or connection string. Like sql.Open, no connection is made
+// until the database is used.
+func Open(dbUrl string) (*DB, error) {
+	if BackendFor(dbUrl) == Postgres {
+		db, err := sql.Open("postgres", dbUrl)
+		if err != nil {
+			return nil, err
+		}
+		return &DB{DB: db, Backend: Postgres}, nil
+	}
+
+	path, dsn, err := sqliteDSN(dbUrl)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("Error creating directory for %s: %v", path, err)
+	}
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &DB{DB: db, Backend: SQLite}, nil
+}
+
+// Returns the path of the database file and the driver's data source name
+func sqliteDSN(dbUrl string) (string, string, error) {
+	_, rest, _ := strings.Cut(dbUrl, ":")
+	path, query, _ := strings.Cut(strings.TrimPrefix(rest, "//"), "?")
+	if path == "" {
+		return "", "", fmt.Errorf("No file in SQLite url %q (expected e.g. sqlite:///home/me/gator.db)", dbUrl)
+	}
+
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", "", fmt.Errorf("Error finding home directory: %v", err)
+		}
+		path = filepath.Join(home, path[1:])
+	}
+
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return "", "", fmt.Errorf("Invalid SQLite url %q: %v", dbUrl, err)
+	}
+	for _, pragma := range sqlitePragmas {
+		params.Add("_pragma", pragma)
+	}
+	// a fixed format in UTC keeps stored times sortable as text
+	params.Set("_time_format", "sqlite")
+
+	return path, "file:" + path + "?" + params.Encode(), nil
+}
+
+// Returns the queries for the database's backend
+func (db *DB) Queries() database.Querier {
+	if db.Backend == SQLite {
+		return sqlitedb.New(db.DB)
+	}
+	return database.New(db.DB)
+}
+
+// Runs fn with queries bound to a transaction. The transaction is
+// committed when fn returns nil and rolled back otherwise.
+func (db *DB) WithTx(ctx context.Context, fn func(database.Querier) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var queries database.Querier
+	if db.Backend == SQLite {
+		queries = sqlitedb.New(db.DB).WithTx(tx)
+	} else {
+		queries = database.New(db.DB).WithTx(tx)
+	}
+
+	if err := fn(queries); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Reports whether err is a unique constraint violation, e.g. a post whose
+// url was already saved
+func IsUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		code := sqliteErr.Code()
+		return code == sqlite3.SQLITE_CONSTRAINT_UNIQUE || code == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+	}
+	return false
+}
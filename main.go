@@ -1,17 +1,23 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"path/filepath"
 	"fmt"
-  "github.com/lib/pq"
+	"github.com/voylento/gator/internal/backup"
 	"github.com/voylento/gator/internal/config"
 	"github.com/voylento/gator/internal/database"
+	"github.com/voylento/gator/internal/migrations"
+	"github.com/voylento/gator/internal/output"
 	"github.com/voylento/gator/internal/rss"
+	"github.com/voylento/gator/internal/storage"
 	"github.com/google/uuid"
 	"html"
+	"io/fs"
 	"log"
 	"os"
 	"os/exec"
@@ -23,133 +29,382 @@ import (
 
 type State struct {
 	config 	*config.Config
-	db 			*database.Queries
+	configOptions	config.Options
+	conn		*storage.DB
+	db 			database.Querier
+	fetcher	rss.Fetcher
+	output	output.Format
+	commands	*CommandMap
 }
 
-type Command struct {
-	name		string
-	args		[]string
+// options that apply to every command, e.g. --output=json
+type GlobalOptions struct {
+	output			output.Format
+	configPath	string
+	profile			string
 }
 
 // cache for the results of the browse command so that the
 // user can open an rss post from the command line
 var cachedPosts []database.GetPostsForUserRow
 
-type CommandInfo struct {
-	handler func(*State, Command) error
-	help string
-}
+func middlewareLoggedIn(handler func(s *State, cmd Command, user database.User) error) func(*State, Command) error{
+	return func(s *State, c Command) error {
+		if s.config.UserName == "" {
+			return newDomainError(errNotLoggedIn, nil, "No user is logged in. Run 'gator register <name>' or 'gator login <name>' first.")
+		}
+
+		user, err := s.db.GetUser(context.Background(), s.config.UserName)
+		if errors.Is(err, sql.ErrNoRows) {
+			return newDomainError(errNotLoggedIn, err, "The current user %v no longer exists. Run 'gator login <name>' to switch user or 'gator register %v' to create it again.", s.config.UserName, s.config.UserName)
+		}
+		if err != nil {
+			return dbError("Failed to read user %v from db: %w", s.config.UserName, err)
+		}
 
-type CommandMap struct {
-	commands	map[string]CommandInfo
+		return handler(s, c, user)
+	}
 }
 
-var commandMap *CommandMap
+// Registers the commands, loads the config and connects to the database.
+// Unless the command opts out, gator refuses to run against a database
+// whose schema is behind the migrations embedded in the binary.
+func InitializeApp(commandName string, opts GlobalOptions) (*State, *CommandMap, error) {
+	cmds := newCommandMap()
 
-func (c *CommandMap) Run(s *State, cmd Command) error {
-	cmdInfo, ok := c.commands[cmd.name]
-	if !ok {
-		return fmt.Errorf("Unknown command: %s\n", cmd.name)
+	cmdInfo, known := cmds.commands[commandName]
+	if known && cmdInfo.usage.SkipConfig {
+		cfg, err := config.NewConfig(opts.configOptions())
+		if err != nil {
+			return nil, cmds, err
+		}
+		fetcher, err := newFetcher(cfg.Fetch)
+		if err != nil {
+			return nil, cmds, err
+		}
+		return &State{config: cfg, configOptions: opts.configOptions(), fetcher: fetcher, output: opts.output}, cmds, nil
 	}
 
-	err := cmdInfo.handler(s, cmd)
+	cfg, err := config.LoadConfig(opts.configOptions())
 	if err != nil {
-		return fmt.Errorf("%w\n", err)
-		os.Exit(1)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, cmds, fmt.Errorf("%w\nRun 'gator init' to set up gator.", err)
+		}
+		return nil, cmds, err
 	}
 
-	return nil
-}
-
-func (c *CommandMap) Register(name string, f func(*State, Command) error, helpText string) {
-	c.commands[name] = CommandInfo{
-		handler: f,
-		help: helpText,
+	db, err := storage.Open(cfg.DbUrl)
+	if err != nil {
+		return nil, cmds, dbError("%w", err)
 	}
-}
+	
+	dbQueries := db.Queries()
 
-func (c *CommandMap) GetHelp(commandName string) (string, bool) {
-	cmdInfo, ok := c.commands[commandName]
-	if !ok {
-		return "", false
+	fetcher, err := newFetcher(cfg.Fetch)
+	if err != nil {
+		return nil, cmds, err
 	}
-	return cmdInfo.help, true
-}
 
-func (c *CommandMap) GetAllCommands() map[string]string {
-	result := make(map[string]string)
-	for name, cmdInfo := range c.commands {
-		result[name] = cmdInfo.help
+	s := &State{
+		config: cfg,
+		configOptions: opts.configOptions(),
+		conn: db,
+		db: dbQueries,
+		fetcher: fetcher,
+		output: opts.output,
 	}
-	return result
-}
 
-func middlewareLoggedIn(handler func(s *State, cmd Command, user database.User) error) func(*State, Command) error{
-	return func(s *State, c Command) error {
-		user, err := s.db.GetUser(context.Background(), s.config.UserName)
-		if err != nil {
-			log.Fatalf("Failed to read user from db: %v", err)
+	if known && !cmdInfo.usage.SkipSchemaCheck {
+		if err := checkSchema(db); err != nil {
+			return nil, cmds, dbError("%w", err)
 		}
-
-		return handler(s, c, user)
 	}
+
+	return s, cmds, nil
 }
 
-func InitializeApp() (*State, *CommandMap) {
+// Registers every command with its handler and usage
+func newCommandMap() *CommandMap {
 	cmds := &CommandMap{
 		commands: 	make(map[string]CommandInfo),
 	}
 
-	commandMap = cmds
+	cmds.Register("addfeed", middlewareLoggedIn(handleAddFeed), Usage{
+		Args:			[]Arg{{Name: "name", Optional: true}, {Name: "url"}},
+		Flags:		[]Flag{
+			{Name: "no-discover", Help: "Add the url as given instead of looking for the feed of a website"},
+		},
+		Summary:	"Add a new RSS feed to follow (the name defaults to the feed's title, website urls are replaced by the feed they link to)",
+	})
+	cmds.Register("agg", handleAgg, Usage{
+		Args:			[]Arg{{Name: "duration"}},
+		Summary:	"Aggregate posts from all followed feeds at duration (1s, 1m, 1h, 5h) intervals",
+	})
+	cmds.Register("allfollows", handleAllFollows, Usage{
+		Summary:	"Show all feed follows across all users",
+	})
+	cmds.Register("backup", handleBackup, Usage{
+		Args:			[]Arg{{Name: "file"}},
+		Flags:		[]Flag{
+			{Name: "force", Help: "Overwrite an existing file"},
+		},
+		Summary:	"Write users, feeds, follows and posts to a JSON file ('-' for stdout)",
+	})
+	cmds.Register("browse", middlewareLoggedIn(handleBrowse), Usage{
+		Args:			[]Arg{{Name: "limit", Optional: true}},
+		Summary:	"Browse recent posts (default limit: 2)",
+	})
+	cmds.Register("config", handleConfig, Usage{
+		Args:			[]Arg{{Name: "show", Values: []string{"show"}}},
+		Summary:	"Show the effective configuration and where each value comes from",
+		SkipSchemaCheck:	true,
+	})
+	cmds.Register("discover", handleDiscover, Usage{
+		Args:			[]Arg{{Name: "url"}},
+		Summary:	"List the RSS and Atom feeds a website advertises",
+		SkipSchemaCheck:	true,
+	})
+	cmds.Register("doctor", handleDoctor, Usage{
+		Flags:		[]Flag{
+			{Name: "fetch", Help: "Also test-fetch every feed"},
+		},
+		Summary:	"Check the config, database, migrations and current user and report problems",
+		SkipConfig:	true,
+	})
+	cmds.Register("feed", middlewareLoggedIn(handleFeed), Usage{
+		Args:			[]Arg{
+			{Name: "rename|seturl|delete", Values: []string{"rename", "seturl", "delete"}},
+			{Name: "url", Complete: completeFeedUrls},
+			{Name: "new_value", Optional: true},
+		},
+		Summary:	"Rename a feed, change its url or delete it (only the feed's creator)",
+	})
+	cmds.Register("feeds", middlewareLoggedIn(handleFeeds), Usage{
+		Summary:	"List all available feeds",
+	})
+	cmds.Register("completion", handleCompletion, Usage{
+		Args:			[]Arg{{Name: "shell", Values: completionShells}},
+		Summary:	"Print a completion script for bash, zsh or fish",
+		SkipConfig:	true,
+	})
+	cmds.Register("__complete", handleComplete, Usage{
+		Args:			[]Arg{{Name: "words", Optional: true, Repeated: true}},
+		Summary:	"Print completion candidates for a partial command line",
+		Hidden:		true,
+		SkipSchemaCheck:	true,
+		SkipConfig:	true,
+	})
+	cmds.Register("follow", middlewareLoggedIn(handleFollow), Usage{
+		Args:			[]Arg{{Name: "feed_url", Complete: completeFeedUrls}},
+		Summary:	"Follow an existing feed",
+	})
+	cmds.Register("following", middlewareLoggedIn(handleFollowing), Usage{
+		Summary:	"List feeds you are following",
+	})
+	cmds.Register("help", handleHelp, Usage{
+		Args:			[]Arg{{Name: "command", Optional: true, Complete: completeCommandNames}},
+		Summary:	"Show help for all commands or a specific command",
+		SkipConfig:	true,
+	})
+	cmds.Register("init", handleInit, Usage{
+		Flags:		[]Flag{
+			{Name: "db-url", Value: "url", Help: "Postgres connection string or sqlite:<file> (prompted for when omitted)"},
+			{Name: "user", Value: "name", Help: "Name of the first user (prompted for when omitted)"},
+			{Name: "force", Help: "Replace the selected profile in an existing config file"},
+		},
+		Summary:	"Set up gator: connect to the database, migrate it, create a user and write the config",
+		SkipConfig:	true,
+	})
+	cmds.Register("login", handleLogin, Usage{
+		Args:			[]Arg{{Name: "username", Complete: completeUserNames}},
+		Summary:	"Login as a user",
+	})
+	cmds.Register("migrate", handleMigrate, Usage{
+		Args:			[]Arg{{Name: "up|down|status", Values: []string{"up", "down", "status"}}},
+		Summary:	"Apply, roll back or list the database schema migrations",
+		SkipSchemaCheck:	true,
+	})
+	cmds.Register("openpost", handleOpenPost, Usage{
+		Args:			[]Arg{{Name: "post_id"}},
+		Summary:	"Open a post from your last browse command in the browser",
+	})
+	cmds.Register("profile", handleProfile, Usage{
+		Args:			[]Arg{
+			{Name: "list|use|add|remove", Values: []string{"list", "use", "add", "remove"}},
+			{Name: "name", Optional: true, Complete: completeProfileNames},
+			{Name: "db_url", Optional: true},
+		},
+		Flags:		[]Flag{
+			{Name: "user", Value: "name", Help: "Current user of a profile created with add"},
+		},
+		Summary:	"Manage named profiles, each with its own database url and current user",
+		SkipSchemaCheck:	true,
+	})
+	cmds.Register("prune", handlePrune, Usage{
+		Flags:		[]Flag{
+			{Name: "dry-run", Help: "Only count the posts that would be deleted"},
+		},
+		Summary:	"Delete posts beyond the retention limits in the config file",
+	})
+	cmds.Register("register", handleRegister, Usage{
+		Args:			[]Arg{{Name: "username"}},
+		Summary:	"Create a new user account",
+	})
+	cmds.Register("reset", handleReset, Usage{
+		Flags:		[]Flag{
+			{Name: "backup", Value: "file", Help: "Write a backup to file before deleting everything (offered when run in a terminal)"},
+		},
+		Summary:	"Reset the database (Warning: Destructive!)",
+	})
+	cmds.Register("restore", handleRestore, Usage{
+		Args:			[]Arg{{Name: "file"}},
+		Flags:		[]Flag{
+			{Name: "replace", Help: "Delete all current data before restoring"},
+		},
+		Summary:	"Restore a backup written by 'gator backup' ('-' for stdin)",
+	})
+	cmds.Register("star", handleStar, Usage{
+		Args:			[]Arg{{Name: "post_id"}},
+		Summary:	"Star a post from your last browse command so that prune keeps it",
+	})
+	cmds.Register("unfollow", middlewareLoggedIn(handleUnfollow), Usage{
+		Args:			[]Arg{{Name: "feed_url", Complete: completeFollowedFeedUrls}},
+		Summary:	"Unfollow a feed",
+	})
+	cmds.Register("unstar", handleStar, Usage{
+		Args:			[]Arg{{Name: "post_id"}},
+		Summary:	"Unstar a post from your last browse command so that prune may delete it",
+	})
+	cmds.Register("users", handleUsers, Usage{
+		Summary:	"Show all registered users",
+	})
+
+	return cmds
+}
 
-	cmds.Register("addfeed", middlewareLoggedIn(handleAddFeed), "addfeed <url> - Add a new RSS feed to follow")
-	cmds.Register("agg", handleAgg, "agg <duration) - Aggregate posts from all followed feeds at duration (1s, 1m, 1hr, 5hrs) intervals")
-	cmds.Register("allfollows", handleAllFollows, "allfollows - Show all feed follows across all users")
-	cmds.Register("browse", middlewareLoggedIn(handleBrowse), "browse [limit] - Browse recent posts (default limit: 2)")
-	cmds.Register("feeds", middlewareLoggedIn(handleFeeds), "feeds - List all available feeds")
-	cmds.Register("follow", middlewareLoggedIn(handleFollow), "follow <feed_url> - Follow an existing feed")
-	cmds.Register("following", middlewareLoggedIn(handleFollowing), "following - List feeds you are following")
-	cmds.Register("help", handleHelp, "help [command] - Show help for all commands or a specific command")
-	cmds.Register("login", handleLogin, "login <username> - Login as a user")
-	cmds.Register("openpost", handleOpenPost, "openpost <post_id> - Open in a post from your last browse command in the browser")
-	cmds.Register("register", handleRegister, "register <username> - Create a new user account")
-	cmds.Register("reset", handleReset, "reset - reset the database (Warning: Destructive!")
-	cmds.Register("unfollow", middlewareLoggedIn(handleUnfollow), "unfollow <feed_url> - Unfollow a feed")
-	cmds.Register("users", handleUsers, "users - Show all registered users")
+// Builds the http client used for feeds from the fetch settings
+func newFetcher(settings config.FetchSettings) (*rss.HTTPFetcher, error) {
+	return rss.NewHTTPFetcher(rss.FetcherOptions{
+		Timeout:					time.Duration(settings.Timeout),
+		UserAgent:				settings.UserAgent,
+		Proxy:						settings.Proxy,
+		MaxResponseBytes:	settings.MaxResponseBytes,
+		MaxAttempts:			settings.MaxAttempts,
+		RetryDelay:				time.Duration(settings.RetryDelay),
+		MaxRetryDelay:		time.Duration(settings.MaxRetryDelay),
+		HostInterval:			time.Duration(settings.HostInterval),
+	})
+}
 
-	cfg, err := config.LoadConfig()
+func checkSchema(db *storage.DB) error {
+	migrator, err := migrations.New(db)
 	if err != nil {
-		fmt.Printf("%v\n", err)
-		os.Exit(1)
+		return err
 	}
 
-	db, err := sql.Open("postgres", cfg.DbUrl)
+	return migrator.CheckCurrent(context.Background())
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+// Runs the command line and returns the exit code. Errors are printed
+// here, once, instead of by the handlers.
+func run(args []string) int {
+	err := runCommandLine(args)
 	if err != nil {
-		fmt.Printf("%v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "%v\n", err)
 	}
-	
-	dbQueries := database.New(db)
 
-	s := &State{
-		config: cfg,
-		db: dbQueries,
+	return exitCode(err)
+}
+
+func runCommandLine(args []string) error {
+	opts, args, err := parseGlobalOptions(args)
+	if err != nil {
+		return usageError("%w", err)
 	}
 
-	return s, cmds
+	var commandArgs []string
+	if len(args) < 1 {
+		return usageError("Usage: gator [--config <file>] [--profile <name>] [--output=text|json|csv|tsv] <command> [arguments]")
+	} else if len(args) > 1 {
+		commandArgs = args[1:]
+	}
+	state, commands, err := InitializeApp(args[0], opts)
+	if err != nil {
+		return err
+	}
+	return runHandler(state, commands, args[0], commandArgs)
 }
 
-func main() {
-	args := os.Args
-	var commandArgs []string
-	if len(args) < 2 {
-		fmt.Println("Usage: gator <command> [arguments]")
-		os.Exit(1)
-	} else if len(args) > 2 {
-		commandArgs = args[2:]
+// Pulls the global options out of the command line and returns the
+// remaining arguments. Global options may appear anywhere before a "--".
+func parseGlobalOptions(args []string) (GlobalOptions, []string, error) {
+	opts := GlobalOptions{
+		output: output.Text,
 	}
-	state, commands := InitializeApp()
-	runHandler(state, commands, args[1], commandArgs)
+
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+
+		name, value, hasValue := strings.Cut(arg, "=")
+		switch name {
+		case "--output", "-o", "--config", "--profile":
+		default:
+			rest = append(rest, arg)
+			continue
+		}
+
+		if !hasValue {
+			if i+1 >= len(args) {
+				return opts, nil, fmt.Errorf("%s requires a value", name)
+			}
+			i++
+			value = args[i]
+		}
+
+		switch name {
+		case "--config":
+			opts.configPath = value
+			continue
+		case "--profile":
+			opts.profile = value
+			continue
+		}
+
+		format, err := output.ParseFormat(value)
+		if err != nil {
+			return opts, nil, err
+		}
+		opts.output = format
+	}
+
+	return opts, rest, nil
+}
+
+func (o GlobalOptions) configOptions() config.Options {
+	return config.Options{
+		Path:			o.configPath,
+		Profile:	o.profile,
+	}
+}
+
+// Emits the records of a listing command. Text output is produced by the
+// handler's own printText func; every other format is rendered from the table.
+func (s *State) printRecords(table output.Table, printText func()) error {
+	if s.output == "" || s.output == output.Text {
+		printText()
+		return nil
+	}
+
+	return output.Write(os.Stdout, s.output, table)
 }
 
 func runHandler(s *State, cmdMap *CommandMap, command string, commandArgs []string) error {
@@ -158,111 +413,236 @@ func runHandler(s *State, cmdMap *CommandMap, command string, commandArgs []stri
 		args: commandArgs,
 	}
 
-	err := cmdMap.Run(s, cmd)
-	if err != nil {
-		fmt.Printf("%v\n", err)
-		os.Exit(1)
-	}
-
-	return nil
+	return cmdMap.Run(s, cmd)
 }
 
+// Adds a feed and follows it. With only a url the feed's name is taken
+// from its channel title. The feed is fetched once so that its description
+// and website are stored and its current posts show up in browse right away.
+// A feed that was already added by someone is simply followed.
 func handleAddFeed(s *State, cmd Command, user database.User) error {
-	if len(cmd.args) != 2 {
-		if helpText, ok := commandMap.GetHelp("addfeed"); ok {
-			fmt.Printf("Usage: %s\n", helpText)
+	var name string
+	feedUrl := cmd.args[len(cmd.args)-1]
+	if len(cmd.args) == 2 {
+		name = cmd.args[0]
+	}
+
+	if !cmd.BoolFlag("no-discover") {
+		discovered, err := discoverFeedUrl(s, feedUrl)
+		if err != nil {
+			return err
 		}
+		feedUrl = discovered
+	}
+
+	ctx := context.Background()
+	existing, err := s.db.GetFeed(ctx, feedUrl)
+	if err == nil {
+		fmt.Printf("Feed %v has already been added as %v\n", feedUrl, existing.Name)
+		follow, err := followFeed(ctx, s.db, user, existing)
+		if err != nil {
+			return err
+		}
+		printFollow(follow)
 		return nil
 	}
-	
+	if !errors.Is(err, sql.ErrNoRows) {
+		return dbError("Error reading feed %v: %w", feedUrl, err)
+	}
+
+	rssFeed, err := s.fetcher.FetchFeed(feedUrl)
+	if err != nil {
+		if name == "" {
+			return networkError("Could not fetch %v to read its title, pass a name instead: %w", feedUrl, err)
+		}
+		fmt.Printf("Warning: could not fetch %v, posts will be collected by agg: %v\n", feedUrl, err)
+	}
+
+	var siteUrl, description string
+	if rssFeed != nil {
+		siteUrl = strings.TrimSpace(rssFeed.Channel.Link)
+		description = strings.TrimSpace(html.UnescapeString(rssFeed.Channel.Description))
+		if name == "" {
+			name = strings.TrimSpace(html.UnescapeString(rssFeed.Channel.Title))
+		}
+	}
+	if name == "" {
+		return usageError("Feed %v has no title, pass a name: addfeed <name> <url>", feedUrl)
+	}
+
 	timeNow := time.Now()
 	feedParams := database.CreateFeedParams{
 		ID:					uuid.New(),
 		CreatedAt:	timeNow,
 		UpdatedAt:	timeNow,
-		Name:				cmd.args[0],
-		Url:				cmd.args[1],
+		Name:				name,
+		Url:				feedUrl,
 		UserID:			user.ID,
+		SiteUrl:		siteUrl,
+		Description:	description,
  	}
 
-	feed, err := s.db.CreateFeed(context.Background(), feedParams)
+	// the feed and its first follow are created together, so a failed
+	// follow does not leave a feed nobody follows. Nothing is printed
+	// until the transaction has committed.
+	var feed database.Feed
+	var follow database.CreateFeedFollowRow
+	err = s.inTx(ctx, func(q database.Querier) error {
+		var err error
+		feed, err = q.CreateFeed(ctx, feedParams)
+		if storage.IsUniqueViolation(err) {
+			return newDomainError(errFeedExists, err, "Feed %v has just been added by someone else. Run 'gator follow %v' to follow it.", feedUrl, feedUrl)
+		}
+		if err != nil {
+			return dbError("Error creating feed %v: %w", feedUrl, err)
+		}
+
+		follow, err = followFeed(ctx, q, user, feed)
+		return err
+	})
 	if err != nil {
-		fmt.Printf("%v\n", err)
-		os.Exit(1)
+		return err
 	}
 
-	fmt.Printf("Create feed %v for %v succeeded\n", cmd.args[0], cmd.args[1])
+	fmt.Printf("Create feed %v for %v succeeded\n", name, feedUrl)
 	fmt.Printf("Feed.ID:\t%v\nFeed.Name:\t%v\nFeed.Url:\t%v\nFeed.UserID:\t%v\n", feed.ID, feed.Name, feed.Url, feed.UserID)
+	printFollow(follow)
 
-	followFeedArgs := []string{
-		cmd.args[1],
+	if rssFeed != nil {
+		created := savePosts(s, feed.ID, feed.Url, rssFeed.Channel.Item, false)
+		fmt.Printf("Saved %d post(s) from %v\n", created, feed.Name)
 	}
 
-	return runHandler(s, commandMap, "follow", followFeedArgs)
+	return nil
 }
 
-func handleAgg(s *State, cmd Command) error {
-	if len(cmd.args) != 1 {
-		if helpText, ok := commandMap.GetHelp("agg"); ok {
-			fmt.Printf("Usage: %s\n", helpText)
+// Runs fn in a database transaction. Without a connection (e.g. State
+// built around an in-memory store) fn runs directly on s.db.
+func (s *State) inTx(ctx context.Context, fn func(database.Querier) error) error {
+	if s.conn == nil {
+		return fn(s.db)
+	}
+
+	return s.conn.WithTx(ctx, fn)
+}
+
+// Returns the feed url to store for a url given to addfeed. Website urls
+// are replaced by the first RSS feed they advertise; when the url cannot be
+// checked (e.g. no network) it is used as given.
+func discoverFeedUrl(s *State, pageUrl string) (string, error) {
+	candidates, err := s.fetcher.Discover(pageUrl)
+	if err != nil {
+		fmt.Printf("Warning: could not check %v for feeds: %v\n", pageUrl, err)
+		return pageUrl, nil
+	}
+
+	// the url is a feed itself; Atom feeds cannot be parsed yet and get
+	// the not found error below
+	if len(candidates) > 0 && candidates[0].URL == pageUrl && candidates[0].Type == rss.TypeRSS {
+		return pageUrl, nil
+	}
+
+	candidate, ok := rss.FirstRSS(candidates)
+	if !ok {
+		return "", notFoundError("No RSS feed found at %v. Run 'gator discover %v' to list the feeds it links to, or use --no-discover to add it anyway.", pageUrl, pageUrl)
+	}
+
+	fmt.Printf("%v is not a feed, using discovered feed %v\n", pageUrl, candidate.URL)
+	for _, other := range candidates {
+		if other.URL != candidate.URL {
+			fmt.Printf("  other feed found: %v (%v)\n", other.URL, other.Type)
 		}
-		return nil
+	}
+	return candidate.URL, nil
+}
+
+func handleDiscover(s *State, cmd Command) error {
+	candidates, err := s.fetcher.Discover(cmd.args[0])
+	if err != nil {
+		return networkError("%w", err)
 	}
 
+	table := output.Table{
+		Columns: []string{"url", "title", "type"},
+	}
+	for _, candidate := range candidates {
+		table.Append(candidate.URL, candidate.Title, candidate.Type)
+	}
+
+	return s.printRecords(table, func() {
+		if len(candidates) == 0 {
+			fmt.Printf("No feeds found at %v\n", cmd.args[0])
+			return
+		}
+		for _, candidate := range candidates {
+			fmt.Printf("%v", candidate.URL)
+			if candidate.Title != "" {
+				fmt.Printf(" - %v", candidate.Title)
+			}
+			fmt.Printf(" (%v)\n", candidate.Type)
+		}
+	})
+}
+
+func handleAgg(s *State, cmd Command) error {
 	duration, err := time.ParseDuration(cmd.args[0])
 	if err != nil {
-		log.Fatalf("Error parsing duration: %v\n", err)
+		return usageError("Invalid duration %q: %w", cmd.args[0], err)
 	}
 
 	fmt.Printf("Collecting feeds every %v\n", duration)
 
+	var prune pruneSchedule
 	ticker := time.NewTicker(duration)
 	for ; ; <-ticker.C {
-		scrapeFeeds(s)
+		if err := scrapeFeeds(s); err != nil {
+			return err
+		}
+		if err := prune.run(s); err != nil {
+			return err
+		}
 	}
-
-	return nil
 }
 
 func handleFeeds(s *State, cmd Command, user database.User) error {
-	if len(cmd.args) > 0 {
-		if helpText, ok := commandMap.GetHelp("feeds"); ok {
-			fmt.Printf("Usage: %s\n", helpText)
-		}
-	}
-
 	feeds, err := s.db.GetAllFeeds(context.Background())
 	if err != nil {
-		log.Fatalf("Error getting all feeds from db: %v", err)
+		return dbError("Error getting all feeds from db: %w", err)
 	}
 
+	table := output.Table{
+		Columns: []string{"id", "name", "url", "site_url", "description", "user_id"},
+	}
 	for _, feed := range feeds {
-		fmt.Printf("Feed Name: %v\n", feed.Name)
-		fmt.Printf("Feed Url: %v\n", feed.Url)
-		fmt.Printf("UserName for Feed: %v\n", user.Name)
+		table.Append(feed.ID, feed.Name, feed.Url, feed.SiteUrl, feed.Description, feed.UserID)
 	}
 
-	return nil
+	return s.printRecords(table, func() {
+		for _, feed := range feeds {
+			fmt.Printf("Feed Name: %v\n", feed.Name)
+			fmt.Printf("Feed Url: %v\n", feed.Url)
+			fmt.Printf("UserName for Feed: %v\n", user.Name)
+		}
+	})
 }
 
 func handleFollow(s *State, cmd Command, user database.User) error {
-	if len(cmd.args) != 1 {
-		if helpText, ok := commandMap.GetHelp("follow"); ok {
-			fmt.Printf("Usage: %s\n", helpText)
-		}
-		return nil
+	feed, err := getFeed(s, cmd.args[0])
+	if err != nil {
+		return err
 	}
-	
-	feed, err := s.db.GetFeed(context.Background(), cmd.args[0])
+
+	follow, err := followFeed(context.Background(), s.db, user, feed)
 	if err != nil {
-		log.Printf("feed %v is not in the list of feeds\n", cmd.args[0])
-		return nil
+		return err
 	}
+	printFollow(follow)
+	return nil
+}
 
-	fmt.Printf("handleFollow, user.ID = %v\n", user.ID)
-	fmt.Printf("handleFollow, feed.ID = %v\n", feed.ID)
-	
-	
+// Makes user follow feed using q, which may be bound to a transaction.
+// Callers report the follow once it is committed.
+func followFeed(ctx context.Context, q database.Querier, user database.User, feed database.Feed) (database.CreateFeedFollowRow, error) {
 	timeNow := time.Now()
 	feedFollowParams := database.CreateFeedFollowParams{
 		ID:					uuid.New(),
@@ -272,90 +652,82 @@ func handleFollow(s *State, cmd Command, user database.User) error {
 		FeedID:			feed.ID,
  	}
 
-	rows, err := s.db.CreateFeedFollow(context.Background(), feedFollowParams)
+	rows, err := q.CreateFeedFollow(ctx, feedFollowParams)
+	if storage.IsUniqueViolation(err) {
+		return database.CreateFeedFollowRow{}, newDomainError(errAlreadyFollowing, err, "%v is already following %v", user.Name, feed.Name)
+	}
 	if err != nil {
-		fmt.Printf("%v\n", err)
-		os.Exit(1)
+		return database.CreateFeedFollowRow{}, dbError("Error following feed %v: %w", feed.Url, err)
 	}
 
 	if len(rows) == 0 {
-		log.Fatal("No rows returned after adding a follow for the feed")
+		return database.CreateFeedFollowRow{}, dbError("No rows returned after adding a follow for the feed")
 	}
 
-	feedFollow := rows[0]
-
-	fmt.Printf("%v is now following %v\n", feedFollow.UserName, feedFollow.FeedName)
+	return rows[0], nil
+}
 
-	return nil
+func printFollow(follow database.CreateFeedFollowRow) {
+	fmt.Printf("%v is now following %v\n", follow.UserName, follow.FeedName)
 }
 
 func handleFollowing(s *State, cmd Command, user database.User) error {
-	if len(cmd.args) > 0 {
-		if helpText, ok := commandMap.GetHelp("following"); ok {
-			fmt.Printf("Usage: %s\n", helpText)
-		}
-		return nil
-	}
-
-	fmt.Printf("calling GetFollowsByUser, ID = %v\n", user.ID)
 	rows, err := s.db.GetFollowsByUser(context.Background(), user.ID)
 	if err != nil {
-		fmt.Printf("%v\n", err)
-		os.Exit(1)
+		return dbError("Error getting feeds followed by %v: %w", user.Name, err)
 	}
 
-	if len(rows) == 0 {
-		fmt.Printf("User %v is not following any rss feeds\n", user.Name)
-		return nil
+	table := output.Table{
+		Columns: []string{"id", "user_name", "feed_id", "feed_name", "feed_url"},
 	}
-
-	fmt.Printf("%v is following:\n", user.Name)
 	for _, feed := range rows {
-		fmt.Printf("%v\n", feed.FeedName)
+		table.Append(feed.ID, feed.UserName, feed.FeedID, feed.FeedName, feed.FeedUrl)
 	}
 
-	return nil
+	return s.printRecords(table, func() {
+		if len(rows) == 0 {
+			fmt.Printf("User %v is not following any rss feeds\n", user.Name)
+			return
+		}
+
+		fmt.Printf("%v is following:\n", user.Name)
+		for _, feed := range rows {
+			fmt.Printf("%v\n", feed.FeedName)
+		}
+	})
 }
 
 func handleAllFollows(s *State, cmd Command) error {
-	if len(cmd.args) > 0 {
-		if helpText, ok := commandMap.GetHelp("allfollows"); ok {
-			fmt.Printf("Usage: %s\n", helpText)
-		}
-		return nil
-	}
-	
 	rows, err := s.db.GetAllFeedFollows(context.Background())
 	if err != nil {
-		fmt.Printf("%v\n", err)
-		os.Exit(1)
+		return dbError("Error getting feed follows: %w", err)
 	}
 
+	table := output.Table{
+		Columns: []string{"id", "created_at", "user_id", "feed_id"},
+	}
 	for _, feed := range rows {
-		fmt.Println("==========")
-		fmt.Printf("ID: %v\n", feed.ID)
-		fmt.Printf("UserID: %v\n", feed.UserID)
-		fmt.Printf("FeedID: %v\n", feed.FeedID)
+		table.Append(feed.ID, feed.CreatedAt, feed.UserID, feed.FeedID)
 	}
 
-	return nil
+	return s.printRecords(table, func() {
+		for _, feed := range rows {
+			fmt.Println("==========")
+			fmt.Printf("ID: %v\n", feed.ID)
+			fmt.Printf("UserID: %v\n", feed.UserID)
+			fmt.Printf("FeedID: %v\n", feed.FeedID)
+		}
+	})
 }
 
 func handleBrowse(s *State, cmd Command, user database.User) error {
 	var limit int32
 	var err error
 	
-	if len(cmd.args) > 1 {
-		if helpText, ok := commandMap.GetHelp("browse"); ok {
-			fmt.Printf("Error: Too many arguments\n")
-			fmt.Printf("Usage: %s\n", helpText)
-		}
-		return nil	
-	} else if len(cmd.args) == 1 {
+	if len(cmd.args) == 1 {
 		limit64, err := strconv.ParseInt(cmd.args[0], 10, 32)
 		if err != nil{
-			fmt.Println("argument to browse must be an integer")
-			return nil
+			return usageError("argument to browse must be an integer")
 		}
 		limit = int32(limit64)
 	} else {
@@ -369,61 +741,196 @@ func handleBrowse(s *State, cmd Command, user database.User) error {
 
 	rows, err := s.db.GetPostsForUser(context.Background(), postsForUserParams)
 	if err != nil {
-		fmt.Errorf("Error getting posts for user %v: %v\n", user.ID, err)
+		return dbError("Error getting posts for user %v: %w", user.ID, err)
 	}
 
 	if err := saveCachedPosts(rows); err != nil {
-			fmt.Printf("Warning: failed to cache posts: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Warning: failed to cache posts: %v\n", err)
 			// Continue anyway - not critical
 	}
-	
+
+	// post_id is the index used by the openpost, star and unstar commands
+	table := output.Table{
+		Columns: []string{"post_id", "feed_name", "title", "published_at", "url", "description", "starred"},
+	}
 	for i, row := range rows {
-		fmt.Println("--------------------")
-		fmt.Printf("Feed Name: %v\n", row.FeedName)
-		fmt.Printf("Title: %v\n", row.Title)
-		fmt.Printf("Publish Date: %v\n", row.PublishedAt)
-		fmt.Printf("[%d] Url: %v\n", i, row.Url)
-		fmt.Printf("Description: %v\n", row.Description)
+		table.Append(i, row.FeedName, row.Title, row.PublishedAt, row.Url, row.Description, row.Starred)
 	}
 
-	return nil
+	return s.printRecords(table, func() {
+		for i, row := range rows {
+			fmt.Println("--------------------")
+			fmt.Printf("Feed Name: %v\n", row.FeedName)
+			fmt.Printf("Title: %v\n", row.Title)
+			if row.Starred {
+				fmt.Println("Starred: yes")
+			}
+			fmt.Printf("Publish Date: %v\n", row.PublishedAt)
+			fmt.Printf("[%d] Url: %v\n", i, row.Url)
+			fmt.Printf("Description: %v\n", row.Description)
+		}
+	})
 }
 
-func handleOpenPost(s *State, cmd Command) error {
-	if len(cmd.args) != 1 {
-		if helpText, ok := commandMap.GetHelp("openpost"); ok {
-			fmt.Printf("Usage: %s\n", helpText)
+func handleConfig(s *State, cmd Command) error {
+	if cmd.args[0] != "show" {
+		return usageError("unknown config action %q (expected show)", cmd.args[0])
+	}
+
+	settings := s.config.Settings()
+	table := output.Table{
+		Columns: []string{"name", "value", "source"},
+	}
+	for _, setting := range settings {
+		table.Append(setting.Name, setting.Value, setting.Source)
+	}
+
+	width := 0
+	for _, setting := range settings {
+		width = max(width, len(setting.Name))
+	}
+
+	return s.printRecords(table, func() {
+		for _, setting := range settings {
+			fmt.Printf("%-*s %-50s (%s)\n", width, setting.Name, setting.Value, setting.Source)
 		}
-		return nil
-	} 
+	})
+}
 
-	postId64, err := strconv.ParseInt(cmd.args[0], 10, 32)
-	if err != nil{
-		fmt.Println("argument to openpost must be an integer")
-		return nil
+func handleProfile(s *State, cmd Command) error {
+	action := cmd.args[0]
+	var name string
+	if len(cmd.args) > 1 {
+		name = cmd.args[1]
 	}
-	postId := int(postId64)
 
-	// Load cached posts
-	cachedPosts, err := loadCachedPosts()
+	switch action {
+	case "list":
+		if len(cmd.args) > 1 {
+			return usageError("Usage: profile list")
+		}
+
+		names := s.config.ProfileNames()
+		table := output.Table{
+			Columns: []string{"name", "db_url", "user_name", "current", "active"},
+		}
+		for _, name := range names {
+			profile, _ := s.config.GetProfile(name)
+			table.Append(name, config.MaskDbUrl(profile.DbUrl), profile.UserName, name == s.config.CurrentProfile(), name == s.config.Profile)
+		}
+
+		return s.printRecords(table, func() {
+			for _, name := range names {
+				profile, _ := s.config.GetProfile(name)
+				marker := " "
+				if name == s.config.CurrentProfile() {
+					marker = "*"
+				}
+				fmt.Printf("%s %-12s %-50s %v\n", marker, name, config.MaskDbUrl(profile.DbUrl), profile.UserName)
+			}
+		})
+	case "use":
+		if len(cmd.args) != 2 {
+			return usageError("Usage: profile use <name>")
+		}
+		if err := s.config.UseProfile(name); err != nil {
+			return err
+		}
+		fmt.Printf("Now using profile %v\n", name)
+	case "add":
+		if len(cmd.args) != 3 {
+			return usageError("Usage: profile add [--user <name>] <name> <db_url>")
+		}
+		profile := config.Profile{
+			DbUrl:			cmd.args[2],
+			UserName:		cmd.Flag("user"),
+		}
+		if err := s.config.AddProfile(name, profile); err != nil {
+			return err
+		}
+		fmt.Printf("Added profile %v. Switch to it with 'gator profile use %v'.\n", name, name)
+	case "remove":
+		if len(cmd.args) != 2 {
+			return usageError("Usage: profile remove <name>")
+		}
+		if err := s.config.RemoveProfile(name); err != nil {
+			return err
+		}
+		fmt.Printf("Removed profile %v\n", name)
+	default:
+		return usageError("unknown profile action %q (expected list, use, add or remove)", action)
+	}
+
+	return nil
+}
+
+func handleMigrate(s *State, cmd Command) error {
+	migrator, err := migrations.New(s.conn)
 	if err != nil {
-			fmt.Println("No cached posts found. Please run 'browse' command first.")
-			return nil
+		return err
 	}
-	// Check if we have cached posts
-	if len(cachedPosts) == 0 {
-		fmt.Println("No posts available. Please run 'browse' command first.")
-		return nil
+
+	ctx := context.Background()
+	switch cmd.args[0] {
+	case "up":
+		versions, err := migrator.Up(ctx)
+		if err != nil {
+			return dbError("%w", err)
+		}
+		if len(versions) == 0 {
+			fmt.Println("Database schema is already up to date")
+			return nil
+		}
+		for _, version := range versions {
+			fmt.Printf("Applied migration %d\n", version)
+		}
+	case "down":
+		version, err := migrator.Down(ctx)
+		if err != nil {
+			return dbError("%w", err)
+		}
+		fmt.Printf("Rolled back migration %d\n", version)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return dbError("%w", err)
+		}
+
+		table := output.Table{
+			Columns: []string{"version", "source", "applied", "applied_at"},
+		}
+		for _, status := range statuses {
+			var appliedAt any
+			if status.Applied {
+				appliedAt = status.AppliedAt
+			}
+			table.Append(status.Version, status.Source, status.Applied, appliedAt)
+		}
+
+		return s.printRecords(table, func() {
+			for _, status := range statuses {
+				if status.Applied {
+					fmt.Printf("%-16d %-45s applied %v\n", status.Version, status.Source, status.AppliedAt.Format(time.RFC1123))
+				} else {
+					fmt.Printf("%-16d %-45s pending\n", status.Version, status.Source)
+				}
+			}
+		})
+	default:
+		return usageError("unknown migrate action %q (expected up, down or status)", cmd.args[0])
 	}
-	
-	// Validate the post ID
-	if postId < 0 || postId >= len(cachedPosts) {
-		fmt.Printf("Invalid post ID. Please use a number between 0 and %d.\n", len(cachedPosts)-1)
-		return nil
+
+	return nil
+}
+
+func handleOpenPost(s *State, cmd Command) error {
+	post, err := cachedPost(cmd)
+	if err != nil {
+		return err
 	}
-	
+
 	// Open the URL in the default browser on macOS
-	url := cachedPosts[postId].Url
+	url := post.Url
 	fmt.Printf("Opening: %s\n", url)
 	
 	exec_cmd := exec.Command("open", url)
@@ -436,31 +943,23 @@ func handleOpenPost(s *State, cmd Command) error {
 }
 
 func handleLogin(s *State, cmd Command) error {
-	if len(cmd.args) == 0 {
-		if helpText, ok := commandMap.GetHelp("login"); ok {
-			fmt.Printf("Usage: %s\n", helpText)
-		}
-		return nil
-	}
-	
 	user, err := s.db.GetUser(context.Background(), cmd.args[0]) 
+	if errors.Is(err, sql.ErrNoRows) {
+		return newDomainError(errUserNotFound, err, "User %v does not exist. Run 'gator users' to list users or 'gator register %v' to create it.", cmd.args[0], cmd.args[0])
+	}
 	if err != nil {
-		log.Fatalf("Failed to read user from db: %v", err)
+		return dbError("Failed to read user %v from db: %w", cmd.args[0], err)
 	}
 
-	s.config.SetUser(user.Name)
+	if err := s.config.SetUser(user.Name); err != nil {
+		return fmt.Errorf("Failed to save current user to config: %v", err)
+	}
 
+	fmt.Printf("Logged in as %v\n", user.Name)
 	return nil
 }
 
 func handleRegister(s *State, cmd Command) error {
-	if len(cmd.args) == 0 {
-		if helpText, ok := commandMap.GetHelp("register"); ok {
-			fmt.Printf("Usage: %s\n", helpText)
-		}
-		return nil
-	}
-	
 	timeNow := time.Now()
 	userParams := database.CreateUserParams{
 		ID:					uuid.New(),
@@ -470,12 +969,16 @@ func handleRegister(s *State, cmd Command) error {
 	}
 
 	user, err := s.db.CreateUser(context.Background(), userParams)
+	if storage.IsUniqueViolation(err) {
+		return newDomainError(errUserExists, err, "User %v already exists. Run 'gator login %v' to use it.", cmd.args[0], cmd.args[0])
+	}
 	if err != nil {
-		fmt.Printf("%v\n", err)
-		os.Exit(1)
+		return dbError("Error creating user %v: %w", cmd.args[0], err)
 	}
 
-	s.config.SetUser(cmd.args[0])
+	if err := s.config.SetUser(user.Name); err != nil {
+		return fmt.Errorf("User %v was registered but could not be saved as the current user: %v\nRun 'gator login %v' to retry.", user.Name, err, user.Name)
+	}
 
 	fmt.Printf("Register %v succeeded\n", cmd.args[0])
 	fmt.Printf("User.ID:\t%v\nUser.CreatedAt:\t%v\nUser.UpdatedAt:\t%v\nUser.Name:\t%v\n", user.ID, user.CreatedAt, user.UpdatedAt, user.Name)
@@ -484,20 +987,37 @@ func handleRegister(s *State, cmd Command) error {
 }
 
 func handleReset(s *State, cmd Command) error {
-	if len(cmd.args) > 0 {
-		if helpText, ok := commandMap.GetHelp("reset"); ok {
-			fmt.Printf("Usage: %s\n", helpText)
+	backupPath := cmd.Flag("backup")
+	if backupPath == "" && isTerminal(os.Stdin) {
+		def := "gator-backup-" + time.Now().Format("20060102-150405") + ".json"
+		answer, err := prompt(bufio.NewReader(os.Stdin), "Back up to file before deleting everything ('-' to skip)", def)
+		if err != nil {
+			return err
 		}
-		return nil
+		if answer != "-" {
+			backupPath = answer
+		}
+	}
+	if backupPath != "" {
+		b, err := backup.Export(context.Background(), s.db)
+		if err != nil {
+			return dbError("%w", err)
+		}
+		if err := writeBackup(backupPath, b, false); err != nil {
+			return err
+		}
+		fmt.Printf("Backed up %s to %v\n", backupSummary(b), backupPath)
 	}
 
 	err := s.db.DeleteAllUsers(context.Background())
 	if err != nil {
-		fmt.Printf("%v\n", err)
-		os.Exit(1)
+		return dbError("Error deleting users: %w", err)
 	}
 
 	fmt.Println("All users deleted from database gator")
+	if backupPath != "" {
+		fmt.Printf("Run 'gator restore %v' to undo\n", backupPath)
+	}
 
 	// err = s.db.DeleteAllFeeds(context.Background())
 	// if err != nil {
@@ -512,26 +1032,58 @@ func handleReset(s *State, cmd Command) error {
 
 func scrapeFeeds(s *State) error {
 	feed, err := s.db.GetNextFeedToFetch(context.Background())
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Println("No active feeds to fetch")
+		return nil
+	}
 	if err != nil {
-		log.Fatalf("Error getting next feed to fectch: %v\n", err)
+		return dbError("Error getting next feed to fetch: %w", err)
 	}
 
 	err = s.db.UpdateFeedFetchTime(context.Background(), feed.ID)
 	if err != nil {
-		log.Fatalf("Error updating feed fetch time: %v\n", err)
+		return dbError("Error updating feed fetch time: %w", err)
 	}
 
-	rss, err := rss.FetchFeed(feed.Url)
+	rssFeed, err := s.fetcher.FetchFeed(feed.Url)
+	if errors.Is(err, rss.ErrGone) {
+		if err := s.db.RetireFeed(context.Background(), feed.ID); err != nil {
+			return dbError("Error retiring feed: %w", err)
+		}
+		log.Printf("Feed %v (%v) is gone (410), it will no longer be fetched\n", feed.Name, feed.Url)
+		return nil
+	}
 	if err != nil {
-		log.Fatalf("Error fetching rss feed %v\n", err)
+		// transient failures were already retried; the feed is tried
+		// again on a later pass rather than stopping agg
+		log.Printf("Error fetching feed %v (%v): %v\n", feed.Name, feed.Url, err)
+		return nil
 	}
 
+	trackFeedMove(s, feed, rssFeed.MovedTo)
+
 	fmt.Println("====================")
-	fmt.Printf("%v\n", rss.Channel.Title)
-	for _, item := range rss.Channel.Item {
-		parsedDate, err := parseRSSDate(item.PubDate)
+	fmt.Printf("%v\n", rssFeed.Channel.Title)
+	savePosts(s, feed.ID, feed.Url, rssFeed.Channel.Item, true)
+
+	return nil
+}
+
+// Stores the items of a feed as posts, skipping items with blank fields,
+// posts that were already saved and posts old enough to be pruned.
+// Returns the number of new posts.
+func savePosts(s *State, feedID uuid.UUID, feedUrl string, items []rss.RSSItem, verbose bool) int {
+	// Items without a usable date get the time they were first seen. Posts
+	// are only saved once, so later fetches do not move them to the top of
+	// browse again.
+	seenAt := time.Now().UTC()
+	cutoff := saveCutoff(s, feedID, feedUrl)
+
+	created := 0
+	for _, item := range items {
+		parsedDate, err := rss.ParseDate(item.PubDate)
 		if err != nil {
-			parsedDate = time.Now()
+			parsedDate = seenAt
 		}
 
 		escapedTitle := strings.TrimSpace(html.UnescapeString(item.Title))
@@ -550,36 +1102,96 @@ func scrapeFeeds(s *State) error {
 			log.Printf("Skipping rss item %v due to blank Description\n", item.Link)
 			continue
 		}
+		if parsedDate.Before(cutoff) {
+			// it would be saved again on every fetch and pruned again
+			continue
+		}
 		
 		postParams := database.CreatePostParams {
 			Title: 				escapedTitle,
 			Url:					escapedUrl,
 			Description: 	escapedDescription,
 			PublishedAt:	parsedDate,
-			FeedID:      	feed.ID,
+			FeedID:      	feedID,
 		}
 
 		post, err := s.db.CreatePost(context.Background(), postParams)
-		fmt.Println("--------------------")
+		if verbose {
+			fmt.Println("--------------------")
+		}
 		if err != nil {
-			pqErr, ok := err.(*pq.Error)
-			if ok && pqErr.Code == "23505" {
-				fmt.Printf("Duplicate key, post not saved\n")
+			if storage.IsUniqueViolation(err) {
+				if verbose {
+					fmt.Printf("Duplicate key, post not saved\n")
+				}
 				continue
 			}
 			fmt.Printf("Error creating post: %v\n", err)
 		} else {
-			fmt.Printf("Post successfully created: %v\n", post.Url)
+			created++
+			if verbose {
+				fmt.Printf("Post successfully created: %v\n", post.Url)
+			}
 		}
 	}
 
-	return nil
+	return created
+}
+
+// Updates a feed's url once it has been permanently redirected to the same
+// new url on enough consecutive fetches. A single redirect is not trusted
+// since publishers sometimes send 301s by mistake.
+func trackFeedMove(s *State, feed database.GetNextFeedToFetchRow, movedTo string) {
+	ctx := context.Background()
+	if movedTo == "" {
+		if err := s.db.ClearFeedRedirect(ctx, feed.ID); err != nil {
+			log.Printf("Error clearing redirect of feed %v: %v\n", feed.Url, err)
+		}
+		return
+	}
+
+	count, err := s.db.RecordFeedRedirect(ctx, database.RecordFeedRedirectParams{
+		ID:						feed.ID,
+		RedirectUrl:	sql.NullString{String: movedTo, Valid: true},
+	})
+	if err != nil {
+		log.Printf("Error recording redirect of feed %v: %v\n", feed.Url, err)
+		return
+	}
+
+	threshold := s.config.Fetch.RedirectsBeforeMove
+	if int(count) < threshold {
+		log.Printf("Feed %v redirected permanently to %v (%d of %d before moving)\n", feed.Url, movedTo, count, threshold)
+		return
+	}
+
+	err = s.db.MoveFeed(ctx, database.MoveFeedParams{
+		ID:		feed.ID,
+		Url:	movedTo,
+	})
+	if storage.IsUniqueViolation(err) {
+		// another feed already has the new url; start counting again
+		// rather than failing the move on every fetch
+		if err := s.db.ClearFeedRedirect(ctx, feed.ID); err != nil {
+			log.Printf("Error clearing redirect of feed %v: %v\n", feed.Url, err)
+		}
+		log.Printf("Feed %v moved to %v, which another feed already uses. Run 'gator follow %v' to follow that feed instead.\n", feed.Url, movedTo, movedTo)
+		return
+	}
+	if err != nil {
+		log.Printf("Error moving feed %v to %v: %v\n", feed.Url, movedTo, err)
+		return
+	}
+	log.Printf("Feed %v moved from %v to %v\n", feed.Name, feed.Url, movedTo)
+	if err := s.config.RenameRetentionFeed(feed.Url, movedTo); err != nil {
+		log.Printf("Error moving the retention limits of %v to %v: %v\n", feed.Url, movedTo, err)
+	}
 }
 
 func handleUnfollow(s *State, cmd Command, user database.User) error {
-	feed, err := s.db.GetFeed(context.Background(), cmd.args[0])
+	feed, err := getFeed(s, cmd.args[0])
 	if err != nil {
-		log.Fatalf("feed %v not found\n", cmd.args[0])
+		return err
 	}
 
 	deleteParams := database.DeleteFeedFollowsParams{
@@ -589,20 +1201,40 @@ func handleUnfollow(s *State, cmd Command, user database.User) error {
 
 	res, err := s.db.DeleteFeedFollows(context.Background(), deleteParams)
 	if err != nil {
-		log.Fatalf("Error unfollowing feed: %v\n", err)
+		return dbError("Error unfollowing feed: %w", err)
 	}
 
-	fmt.Printf("Unfollow returned %v\n", res)
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return dbError("Error unfollowing feed: %w", err)
+	}
+	if deleted == 0 {
+		return newDomainError(errNotFollowing, nil, "%v is not following %v", user.Name, feed.Name)
+	}
+
+	fmt.Printf("%v unfollowed %v\n", user.Name, feed.Name)
 	return nil
 }
 
+// Looks up a feed by url for the commands that take one
+func getFeed(s *State, url string) (database.Feed, error) {
+	feed, err := s.db.GetFeed(context.Background(), url)
+	if errors.Is(err, sql.ErrNoRows) {
+		return feed, newDomainError(errFeedNotFound, err, "Feed %v not found. Run 'gator feeds' to list feeds or 'gator addfeed %v' to add it.", url, url)
+	}
+	if err != nil {
+		return feed, dbError("Error reading feed %v: %w", url, err)
+	}
+	return feed, nil
+}
+
 func handleHelp(s *State, cmd Command) error {
 	if len(cmd.args) == 0 {
 			// Show all commands
 			fmt.Println("Available commands:")
 			fmt.Println("==================")
 			
-			allCommands := commandMap.GetAllCommands()
+			allCommands := s.commands.GetAllCommands()
 			
 			// Sort commands alphabetically for consistent output
 			var sortedNames []string
@@ -615,71 +1247,43 @@ func handleHelp(s *State, cmd Command) error {
 					fmt.Printf("  %s\n", allCommands[name])
 			}
 			
-			fmt.Println("\nUse 'help <command>' for detailed help on a specific command.")
+			fmt.Println("\nUse 'help <command>' or '<command> --help' for detailed help on a specific command.")
 			return nil
 	}
 	
-	if len(cmd.args) == 1 {
-			// Show help for specific command
-			commandName := cmd.args[0]
-			helpText, exists := commandMap.GetHelp(commandName)
-			if !exists {
-					return fmt.Errorf("Unknown command: %s", commandName)
-			}
-			fmt.Printf("%s\n", helpText)
-			return nil
+	// Show help for specific command
+	commandName := cmd.args[0]
+	helpText, exists := s.commands.GetHelp(commandName)
+	if !exists {
+			return usageError("Unknown command: %s", commandName)
 	}
-	
-	return fmt.Errorf("Usage: help [command]")
+	fmt.Printf("%s\n", helpText)
+	return nil
 }
 
 // Prints all users registered with the application
 func handleUsers(s *State, cmd Command) error {
-	if len(cmd.args) > 0 {
-		if helpText, ok := commandMap.GetHelp("users"); ok {
-			fmt.Printf("Usage: %s\n", helpText)
-		}
-		return nil
-	}
-
 	users, err := s.db.GetUsers(context.Background())
 	if err != nil {
-		fmt.Printf("%v\n", err)
-		os.Exit(1)
+		return dbError("Error getting users: %w", err)
 	}
 
-	for _, user := range users {
-		if user.Name == s.config.UserName {
-			fmt.Printf("* %v (current)\n", user.Name)
-		} else {
-			fmt.Printf("* %v\n", user.Name)
-		}
+	table := output.Table{
+		Columns: []string{"id", "name", "created_at", "current"},
 	}
-
-	return nil
-}
-
-func parseRSSDate(dateStr string) (time.Time, error) {
-	layouts := []string {
-		"Mon, 02 Jan 2006 15:04:05 -0700", 	// RFC822 with numeric zone
-		"Mon, 02 Jan 2006 15:04:05 MST",  	// RFC822 with named zone
-		"2006-01-02T15:04:05Z",							// ISO8601/RFC3339
-		"2006-01-02T15:04:05-07:00",				// ISO8601 with offset
-		"2006-01-02 15:04:05-0700 MST",		  // Go's time.RFC1123Z format
-		"02 Jan 2006 15:04:05 -0700",				// Some other common format
+	for _, user := range users {
+		table.Append(user.ID, user.Name, user.CreatedAt, user.Name == s.config.UserName)
 	}
 
-	var parsedTime time.Time
-	var err error
-
-	for _, layout := range layouts {
-		parsedTime, err = time.Parse(layout, dateStr)
-		if err == nil {
-			return parsedTime, nil
+	return s.printRecords(table, func() {
+		for _, user := range users {
+			if user.Name == s.config.UserName {
+				fmt.Printf("* %v (current)\n", user.Name)
+			} else {
+				fmt.Printf("* %v\n", user.Name)
+			}
 		}
-	}
-
-	return time.Time{}, fmt.Errorf("unable to parse date %v\n", dateStr)
+	})
 }
 
 func getCacheFilePath() string {
@@ -694,6 +1298,32 @@ func saveCachedPosts(posts []database.GetPostsForUserRow) error {
     return os.WriteFile(getCacheFilePath(), data, 0644)
 }
 
+// Returns the post that the last browse command listed under the post_id
+// given as the command's first argument
+func cachedPost(cmd Command) (database.GetPostsForUserRow, error) {
+	postId64, err := strconv.ParseInt(cmd.args[0], 10, 32)
+	if err != nil{
+		return database.GetPostsForUserRow{}, usageError("argument to %v must be an integer", cmd.name)
+	}
+	postId := int(postId64)
+
+	// Load cached posts
+	cachedPosts, err := loadCachedPosts()
+	if err != nil {
+			return database.GetPostsForUserRow{}, notFoundError("No cached posts found. Please run 'browse' command first.")
+	}
+	// Check if we have cached posts
+	if len(cachedPosts) == 0 {
+		return database.GetPostsForUserRow{}, notFoundError("No posts available. Please run 'browse' command first.")
+	}
+	
+	// Validate the post ID
+	if postId < 0 || postId >= len(cachedPosts) {
+		return database.GetPostsForUserRow{}, notFoundError("Invalid post ID. Please use a number between 0 and %d.", len(cachedPosts)-1)
+	}
+	return cachedPosts[postId], nil
+}
+
 func loadCachedPosts() ([]database.GetPostsForUserRow, error) {
     data, err := os.ReadFile(getCacheFilePath())
     if err != nil {
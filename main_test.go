@@ -0,0 +1,619 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/voylento/gator/internal/config"
+	"github.com/voylento/gator/internal/database"
+	"github.com/voylento/gator/internal/database/memdb"
+	"github.com/voylento/gator/internal/migrations"
+	"github.com/voylento/gator/internal/output"
+	"github.com/voylento/gator/internal/rss"
+	"github.com/voylento/gator/internal/rss/rsstest"
+	"github.com/voylento/gator/internal/storage"
+)
+
+// The handler tests run against the in-memory store and against a SQLite
+// file, so the hand-written SQLite queries and migrations are exercised
+// without a database server
+var testBackends = []string{"memdb", "sqlite"}
+
+func forEachBackend(t *testing.T, test func(t *testing.T, backend string)) {
+	for _, backend := range testBackends {
+		t.Run(backend, func(t *testing.T) {
+			test(t, backend)
+		})
+	}
+}
+
+// Returns a State backed by a fresh database of the given backend and a
+// config file in a temp directory
+func newTestState(t *testing.T, backend string, fetcher rss.Fetcher) *State {
+	t.Helper()
+
+	dir := t.TempDir()
+	cfg, err := config.NewConfig(config.Options{Path: filepath.Join(dir, "config.json")})
+	if err != nil {
+		t.Fatalf("NewConfig: %v", err)
+	}
+	s := &State{
+		config:  cfg,
+		fetcher: fetcher,
+		output:  output.Text,
+	}
+
+	switch backend {
+	case "memdb":
+		s.db = memdb.New()
+	case "sqlite":
+		db, err := storage.Open("sqlite:" + filepath.Join(dir, "gator.db"))
+		if err != nil {
+			t.Fatalf("storage.Open: %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		migrator, err := migrations.New(db)
+		if err != nil {
+			t.Fatalf("migrations.New: %v", err)
+		}
+		if _, err := migrator.Up(context.Background()); err != nil {
+			t.Fatalf("migrating: %v", err)
+		}
+		s.conn = db
+		s.db = db.Queries()
+	default:
+		t.Fatalf("unknown backend %q", backend)
+	}
+	return s
+}
+
+// Adds a user and a feed with the given url directly to the database
+func addTestFeed(t *testing.T, s *State, feedUrl string) database.Feed {
+	t.Helper()
+
+	ctx := context.Background()
+	timeNow := time.Now()
+	user, err := s.db.GetUser(ctx, "owner")
+	if errors.Is(err, sql.ErrNoRows) {
+		user, err = s.db.CreateUser(ctx, database.CreateUserParams{
+			ID:        uuid.New(),
+			CreatedAt: timeNow,
+			UpdatedAt: timeNow,
+			Name:      "owner",
+		})
+	}
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	feed, err := s.db.CreateFeed(ctx, database.CreateFeedParams{
+		ID:        uuid.New(),
+		CreatedAt: timeNow,
+		UpdatedAt: timeNow,
+		Name:      "Test feed",
+		Url:       feedUrl,
+		UserID:    user.ID,
+	})
+	if err != nil {
+		t.Fatalf("CreateFeed: %v", err)
+	}
+	return feed
+}
+
+func postTitles(t *testing.T, s *State) []string {
+	t.Helper()
+
+	posts, err := s.db.GetAllPosts(context.Background())
+	if err != nil {
+		t.Fatalf("GetAllPosts: %v", err)
+	}
+	var titles []string
+	for _, post := range posts {
+		titles = append(titles, post.Title)
+	}
+	slices.Sort(titles)
+	return titles
+}
+
+func TestScrapeFeedsSavesPostsOnce(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		server := rsstest.NewServer()
+		defer server.Close()
+		server.HandleFixture("/duplicates.xml", rsstest.FeedDuplicates)
+
+		s := newTestState(t, backend, rsstest.NewFetcher())
+		addTestFeed(t, s, server.FeedURL("/duplicates.xml"))
+
+		// the second pass fetches the same feed again
+		for range 2 {
+			if err := scrapeFeeds(s); err != nil {
+				t.Fatalf("scrapeFeeds: %v", err)
+			}
+		}
+
+		want := []string{"Repeated post", "Unique post"}
+		if got := postTitles(t, s); !slices.Equal(got, want) {
+			t.Errorf("posts = %q, want %q", got, want)
+		}
+		if hits := server.Hits("/duplicates.xml"); hits != 2 {
+			t.Errorf("feed fetched %d times, want 2", hits)
+		}
+	})
+}
+
+func TestScrapeFeedsSkipsBlankFields(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		server := rsstest.NewServer()
+		defer server.Close()
+		server.HandleFixture("/blank.xml", rsstest.FeedBlankFields)
+
+		s := newTestState(t, backend, rsstest.NewFetcher())
+		addTestFeed(t, s, server.FeedURL("/blank.xml"))
+
+		if err := scrapeFeeds(s); err != nil {
+			t.Fatalf("scrapeFeeds: %v", err)
+		}
+
+		want := []string{"Complete post"}
+		if got := postTitles(t, s); !slices.Equal(got, want) {
+			t.Errorf("posts = %q, want %q", got, want)
+		}
+	})
+}
+
+func TestScrapeFeedsFetchFailure(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		server := rsstest.NewServer()
+		defer server.Close()
+		server.Fail("/broken.xml", 500)
+
+		s := newTestState(t, backend, rsstest.NewFetcher())
+		feed := addTestFeed(t, s, server.FeedURL("/broken.xml"))
+
+		// a failing feed is logged and agg keeps running
+		if err := scrapeFeeds(s); err != nil {
+			t.Fatalf("scrapeFeeds returned %v, want nil", err)
+		}
+
+		stored, err := s.db.GetFeed(context.Background(), feed.Url)
+		if err != nil {
+			t.Fatalf("GetFeed: %v", err)
+		}
+		if !stored.LastFetchedAt.Valid {
+			t.Error("last_fetched_at not updated after a failed fetch")
+		}
+		if stored.RetiredAt.Valid {
+			t.Error("feed retired after a 500")
+		}
+		if got := postTitles(t, s); len(got) != 0 {
+			t.Errorf("posts = %q, want none", got)
+		}
+		if hits := server.Hits("/broken.xml"); hits < 2 {
+			t.Errorf("feed fetched %d times, want retries", hits)
+		}
+	})
+}
+
+func TestScrapeFeedsRetiresGoneFeed(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		server := rsstest.NewServer()
+		defer server.Close()
+		server.Fail("/gone.xml", 410)
+
+		s := newTestState(t, backend, rsstest.NewFetcher())
+		feed := addTestFeed(t, s, server.FeedURL("/gone.xml"))
+
+		if err := scrapeFeeds(s); err != nil {
+			t.Fatalf("scrapeFeeds: %v", err)
+		}
+
+		stored, err := s.db.GetFeed(context.Background(), feed.Url)
+		if err != nil {
+			t.Fatalf("GetFeed: %v", err)
+		}
+		if !stored.RetiredAt.Valid {
+			t.Error("feed answering 410 was not retired")
+		}
+
+		// retired feeds are no longer fetched
+		if err := scrapeFeeds(s); err != nil {
+			t.Fatalf("scrapeFeeds: %v", err)
+		}
+		if hits := server.Hits("/gone.xml"); hits != 1 {
+			t.Errorf("feed fetched %d times, want 1", hits)
+		}
+	})
+}
+
+func TestScrapeFeedsFetchesLeastRecentlyFetchedFirst(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		server := rsstest.NewServer()
+		defer server.Close()
+		paths := []string{"/a.xml", "/b.xml", "/c.xml"}
+		for _, path := range paths {
+			server.HandleFixture(path, rsstest.FeedBasic)
+		}
+
+		s := newTestState(t, backend, rsstest.NewFetcher())
+		for _, path := range paths {
+			addTestFeed(t, s, server.FeedURL(path))
+		}
+
+		// returns the path fetched by one pass
+		scrape := func() string {
+			t.Helper()
+			before := make(map[string]int)
+			for _, path := range paths {
+				before[path] = server.Hits(path)
+			}
+			if err := scrapeFeeds(s); err != nil {
+				t.Fatalf("scrapeFeeds: %v", err)
+			}
+			// keeps last_fetched_at apart on clocks with coarse resolution
+			time.Sleep(10 * time.Millisecond)
+			for _, path := range paths {
+				if server.Hits(path) > before[path] {
+					return path
+				}
+			}
+			t.Fatal("scrapeFeeds fetched no feed")
+			return ""
+		}
+
+		// feeds never fetched come first, then the one fetched longest ago
+		var order []string
+		for range 3 {
+			order = append(order, scrape())
+		}
+		sorted := slices.Clone(order)
+		slices.Sort(sorted)
+		if !slices.Equal(sorted, paths) {
+			t.Fatalf("first three passes fetched %q, want each feed once", order)
+		}
+		if next := scrape(); next != order[0] {
+			t.Errorf("fourth pass fetched %v, want %v which was fetched longest ago", next, order[0])
+		}
+	})
+}
+
+// Serves canned feeds by url; every known feed is its own discovery result
+type fakeFetcher struct {
+	feeds map[string]*rss.RSSFeed
+}
+
+func (f *fakeFetcher) FetchFeed(feedUrl string) (*rss.RSSFeed, error) {
+	feed, ok := f.feeds[feedUrl]
+	if !ok {
+		return nil, fmt.Errorf("no feed at %v", feedUrl)
+	}
+	return feed, nil
+}
+
+func (f *fakeFetcher) Discover(pageUrl string) ([]rss.Candidate, error) {
+	if _, ok := f.feeds[pageUrl]; !ok {
+		return nil, nil
+	}
+	return []rss.Candidate{{URL: pageUrl, Type: rss.TypeRSS}}, nil
+}
+
+func newFakeFeed(title string, items ...rss.RSSItem) *rss.RSSFeed {
+	feed := &rss.RSSFeed{}
+	feed.Channel.Title = title
+	feed.Channel.Item = items
+	return feed
+}
+
+// Runs a command line through the command map like gator does
+func runCommand(s *State, name string, args ...string) error {
+	return newCommandMap().Run(s, Command{name: name, args: args})
+}
+
+func mustRun(t *testing.T, s *State, name string, args ...string) {
+	t.Helper()
+	if err := runCommand(s, name, args...); err != nil {
+		t.Fatalf("%v %q: %v", name, args, err)
+	}
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		s := newTestState(t, backend, &fakeFetcher{})
+
+		mustRun(t, s, "register", "alice")
+		if s.config.UserName != "alice" {
+			t.Errorf("current user = %q after register, want alice", s.config.UserName)
+		}
+
+		err := runCommand(s, "register", "alice")
+		if !errors.Is(err, errUserExists) {
+			t.Fatalf("duplicate register returned %v, want errUserExists", err)
+		}
+		if code := exitCode(err); code != exitConflict {
+			t.Errorf("duplicate register exit code = %d, want %d", code, exitConflict)
+		}
+
+		mustRun(t, s, "register", "bob")
+		mustRun(t, s, "login", "alice")
+		if s.config.UserName != "alice" {
+			t.Errorf("current user = %q after login, want alice", s.config.UserName)
+		}
+
+		err = runCommand(s, "login", "carol")
+		if !errors.Is(err, errUserNotFound) {
+			t.Errorf("login of unknown user returned %v, want errUserNotFound", err)
+		}
+		if code := exitCode(err); code != exitNotFound {
+			t.Errorf("login of unknown user exit code = %d, want %d", code, exitNotFound)
+		}
+	})
+}
+
+func TestCommandMapUsageErrors(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		s := newTestState(t, backend, &fakeFetcher{})
+
+		tests := []struct {
+			name string
+			args []string
+		}{
+			{"no-such-command", nil},
+			{"register", nil},
+			{"register", []string{"alice", "bob"}},
+			{"addfeed", []string{"--no-such-flag", "https://example.com/feed.xml"}},
+		}
+		for _, tt := range tests {
+			err := runCommand(s, tt.name, tt.args...)
+			if code := exitCode(err); code != exitUsage {
+				t.Errorf("%v %q: exit code %d (%v), want %d", tt.name, tt.args, code, err, exitUsage)
+			}
+		}
+
+		err := runCommand(s, "browse")
+		if !errors.Is(err, errNotLoggedIn) {
+			t.Errorf("browse without a user returned %v, want errNotLoggedIn", err)
+		}
+	})
+}
+
+func TestAddFeedFollowAndUnfollow(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		const feedUrl = "https://example.com/feed.xml"
+		s := newTestState(t, backend, &fakeFetcher{feeds: map[string]*rss.RSSFeed{
+			feedUrl: newFakeFeed("Example"),
+		}})
+		ctx := context.Background()
+
+		mustRun(t, s, "register", "alice")
+		mustRun(t, s, "addfeed", feedUrl)
+
+		feed, err := s.db.GetFeed(ctx, feedUrl)
+		if err != nil {
+			t.Fatalf("GetFeed after addfeed: %v", err)
+		}
+		if feed.Name != "Example" {
+			t.Errorf("feed name = %q, want the channel title", feed.Name)
+		}
+
+		err = runCommand(s, "follow", feedUrl)
+		if !errors.Is(err, errAlreadyFollowing) {
+			t.Errorf("second follow returned %v, want errAlreadyFollowing", err)
+		}
+		err = runCommand(s, "addfeed", "Other name", feedUrl)
+		if !errors.Is(err, errAlreadyFollowing) {
+			t.Errorf("addfeed of a followed feed returned %v, want errAlreadyFollowing", err)
+		}
+
+		mustRun(t, s, "register", "bob")
+		mustRun(t, s, "follow", feedUrl)
+		mustRun(t, s, "unfollow", feedUrl)
+
+		err = runCommand(s, "unfollow", feedUrl)
+		if !errors.Is(err, errNotFollowing) {
+			t.Fatalf("unfollow without a follow returned %v, want errNotFollowing", err)
+		}
+		if code := exitCode(err); code != exitNotFound {
+			t.Errorf("unfollow without a follow exit code = %d, want %d", code, exitNotFound)
+		}
+
+		err = runCommand(s, "follow", "https://example.com/missing.xml")
+		if !errors.Is(err, errFeedNotFound) {
+			t.Errorf("follow of unknown feed returned %v, want errFeedNotFound", err)
+		}
+
+		// alice still follows the feed added first
+		alice, err := s.db.GetUser(ctx, "alice")
+		if err != nil {
+			t.Fatalf("GetUser: %v", err)
+		}
+		follows, err := s.db.GetFollowsByUser(ctx, alice.ID)
+		if err != nil {
+			t.Fatalf("GetFollowsByUser: %v", err)
+		}
+		if len(follows) != 1 {
+			t.Errorf("alice has %d follows, want 1", len(follows))
+		}
+	})
+}
+
+func TestFeedChangeByAnotherUser(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		const feedUrl = "https://example.com/feed.xml"
+		s := newTestState(t, backend, &fakeFetcher{feeds: map[string]*rss.RSSFeed{
+			feedUrl: newFakeFeed("Example"),
+		}})
+
+		mustRun(t, s, "register", "alice")
+		mustRun(t, s, "addfeed", feedUrl)
+		mustRun(t, s, "register", "bob")
+
+		err := runCommand(s, "feed", "rename", feedUrl, "Mine now")
+		if !errors.Is(err, errNotFeedOwner) {
+			t.Fatalf("rename by another user returned %v, want errNotFeedOwner", err)
+		}
+		if code := exitCode(err); code != exitConflict {
+			t.Errorf("rename by another user exit code = %d, want %d", code, exitConflict)
+		}
+	})
+}
+
+func TestBackupRefusesExistingFile(t *testing.T) {
+	s := newTestState(t, "memdb", &fakeFetcher{})
+	path := filepath.Join(t.TempDir(), "backup.json")
+	if err := os.WriteFile(path, []byte("keep me"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	err := runCommand(s, "backup", path)
+	if code := exitCode(err); code != exitConflict {
+		t.Errorf("backup over an existing file exit code = %d (%v), want %d", code, err, exitConflict)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "keep me" {
+		t.Errorf("existing file was overwritten without --force")
+	}
+
+	mustRun(t, s, "backup", "--force", path)
+}
+
+func TestBrowseReturnsNewestPostsFirst(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		// browse caches the listed posts for openpost in the temp directory
+		t.Setenv("TMPDIR", t.TempDir())
+
+		const feedUrl = "https://example.com/feed.xml"
+		s := newTestState(t, backend, &fakeFetcher{feeds: map[string]*rss.RSSFeed{
+			feedUrl: newFakeFeed("Example",
+				rss.RSSItem{Title: "Middle", Link: "https://example.com/2", Description: "two", PubDate: "Tue, 06 Oct 2026 09:00:00 GMT"},
+				rss.RSSItem{Title: "Oldest", Link: "https://example.com/1", Description: "one", PubDate: "Mon, 05 Oct 2026 09:00:00 GMT"},
+				rss.RSSItem{Title: "Newest", Link: "https://example.com/3", Description: "three", PubDate: "2026-10-07T09:00:00Z"},
+			),
+		}})
+
+		mustRun(t, s, "register", "alice")
+		mustRun(t, s, "addfeed", "Example", feedUrl)
+
+		tests := []struct {
+			args []string
+			want []string
+		}{
+			{nil, []string{"Newest", "Middle"}},
+			{[]string{"10"}, []string{"Newest", "Middle", "Oldest"}},
+			{[]string{"1"}, []string{"Newest"}},
+		}
+		for _, tt := range tests {
+			mustRun(t, s, "browse", tt.args...)
+
+			posts, err := loadCachedPosts()
+			if err != nil {
+				t.Fatalf("loadCachedPosts: %v", err)
+			}
+			var got []string
+			for _, post := range posts {
+				got = append(got, post.Title)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("browse %q = %q, want %q", tt.args, got, tt.want)
+			}
+		}
+
+		err := runCommand(s, "browse", "many")
+		if code := exitCode(err); code != exitUsage {
+			t.Errorf("browse many exit code = %d, want %d", code, exitUsage)
+		}
+
+		// users who follow nothing see no posts
+		mustRun(t, s, "register", "bob")
+		mustRun(t, s, "browse", "10")
+		posts, err := loadCachedPosts()
+		if err != nil {
+			t.Fatalf("loadCachedPosts: %v", err)
+		}
+		if len(posts) != 0 {
+			t.Errorf("bob sees %d posts, want none", len(posts))
+		}
+	})
+}
+
+func TestAddFeedWithoutTitle(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		const feedUrl = "https://example.com/untitled.xml"
+		s := newTestState(t, backend, &fakeFetcher{feeds: map[string]*rss.RSSFeed{
+			feedUrl: newFakeFeed("  "),
+		}})
+
+		mustRun(t, s, "register", "alice")
+		err := runCommand(s, "addfeed", feedUrl)
+		if code := exitCode(err); code != exitUsage {
+			t.Errorf("addfeed of an untitled feed exit code = %d (%v), want %d", code, err, exitUsage)
+		}
+		if _, err := s.db.GetFeed(context.Background(), feedUrl); err == nil {
+			t.Error("untitled feed was added")
+		}
+
+		mustRun(t, s, "addfeed", "Untitled", feedUrl)
+	})
+}
+
+// addfeed creates the feed, follows it and saves its posts in one
+// transaction, so a failed follow leaves nothing behind
+func TestAddFeedRollsBackWhenFollowFails(t *testing.T) {
+	const feedUrl = "https://example.com/feed.xml"
+	s := newTestState(t, "sqlite", &fakeFetcher{feeds: map[string]*rss.RSSFeed{
+		feedUrl: newFakeFeed("Example", rss.RSSItem{Title: "First", Link: "https://example.com/1"}),
+	}})
+	mustRun(t, s, "register", "alice")
+
+	_, err := s.conn.Exec(`CREATE TRIGGER fail_follow BEFORE INSERT ON feed_follows
+		BEGIN SELECT RAISE(ABORT, 'follow failed'); END`)
+	if err != nil {
+		t.Fatalf("creating trigger: %v", err)
+	}
+
+	if err := runCommand(s, "addfeed", feedUrl); err == nil {
+		t.Fatal("addfeed succeeded although the follow insert fails")
+	}
+	if _, err := s.db.GetFeed(context.Background(), feedUrl); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetFeed after the failed addfeed returned %v, want sql.ErrNoRows", err)
+	}
+	if got := postTitles(t, s); len(got) != 0 {
+		t.Errorf("saved posts %q after the failed addfeed, want none", got)
+	}
+}
+
+func TestAddFeedDiscovery(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		server := rsstest.NewServer()
+		defer server.Close()
+		server.HandleFixture("/", rsstest.PageWithFeeds)
+		server.HandleFixture("/feed.xml", rsstest.FeedBasic)
+		server.HandleFixture("/atom.xml", rsstest.FeedAtom)
+
+		s := newTestState(t, backend, rsstest.NewFetcher())
+		mustRun(t, s, "register", "alice")
+
+		// a homepage is replaced by the RSS feed it links to
+		mustRun(t, s, "addfeed", server.FeedURL("/"))
+		if _, err := s.db.GetFeed(context.Background(), server.FeedURL("/feed.xml")); err != nil {
+			t.Errorf("discovered feed was not added: %v", err)
+		}
+		if got := postTitles(t, s); len(got) != 3 {
+			t.Errorf("saved posts %q, want the 3 posts of the feed", got)
+		}
+
+		// Atom feeds cannot be read, so addfeed refuses them
+		err := runCommand(s, "addfeed", server.FeedURL("/atom.xml"))
+		if code := exitCode(err); code != exitNotFound {
+			t.Errorf("addfeed of an Atom feed exit code = %d (%v), want %d", code, err, exitNotFound)
+		}
+		if _, err := s.db.GetFeed(context.Background(), server.FeedURL("/atom.xml")); err == nil {
+			t.Error("Atom feed was added")
+		}
+	})
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/voylento/gator/internal/config"
+	"github.com/voylento/gator/internal/database"
+	"github.com/voylento/gator/internal/output"
+)
+
+// Posts pruned (or, with --dry-run, to be pruned) from a single feed
+type pruneResult struct {
+	feed  database.Feed
+	posts int64
+}
+
+// Deletes posts beyond the retention limits in the config file. With
+// --dry-run the posts are only counted.
+func handlePrune(s *State, cmd Command) error {
+	if !s.config.Retention.Enabled() {
+		fmt.Println("No retention limits configured, all posts are kept. Set retention.max_age or retention.max_posts_per_feed in the config file.")
+		return nil
+	}
+
+	dryRun := cmd.BoolFlag("dry-run")
+	results, err := prunePosts(context.Background(), s, dryRun)
+	if err != nil {
+		return err
+	}
+
+	table := output.Table{
+		Columns: []string{"feed_id", "feed_name", "feed_url", "posts"},
+	}
+	var total int64
+	for _, result := range results {
+		table.Append(result.feed.ID, result.feed.Name, result.feed.Url, result.posts)
+		total += result.posts
+	}
+
+	verb := "Pruned"
+	if dryRun {
+		verb = "Would prune"
+	}
+	return s.printRecords(table, func() {
+		for _, result := range results {
+			fmt.Printf("%v %d post(s) from %v\n", verb, result.posts, result.feed.Name)
+		}
+		fmt.Printf("%v %d post(s) in total\n", verb, total)
+	})
+}
+
+// Prunes the posts of every feed that has retention limits and returns the
+// feeds that had posts to prune
+func prunePosts(ctx context.Context, s *State, dryRun bool) ([]pruneResult, error) {
+	feeds, err := s.db.GetAllFeeds(ctx)
+	if err != nil {
+		return nil, dbError("Error getting all feeds from db: %w", err)
+	}
+
+	// limits for a url that no feed uses are most likely a typo or a feed
+	// that has been deleted
+	feedUrls := make([]string, 0, len(feeds))
+	for _, feed := range feeds {
+		feedUrls = append(feedUrls, feed.Url)
+	}
+	for _, url := range s.config.Retention.UnknownFeeds(feedUrls) {
+		log.Printf("Warning: retention.feeds has limits for %v, which is not the url of any feed\n", url)
+	}
+
+	var results []pruneResult
+	for _, feed := range feeds {
+		limits := s.config.Retention.For(feed.Url)
+		if limits.MaxAge <= 0 && limits.MaxPosts <= 0 {
+			continue
+		}
+
+		limit := int32(math.MaxInt32)
+		if limits.MaxPosts > 0 {
+			limit = int32(min(limits.MaxPosts, math.MaxInt32))
+		}
+
+		var count int64
+		if dryRun {
+			count, err = s.db.CountPostsToPrune(ctx, database.CountPostsToPruneParams{
+				FeedID:      feed.ID,
+				PublishedAt: pruneCutoff(limits),
+				Limit:       limit,
+			})
+		} else {
+			count, err = s.db.PrunePosts(ctx, database.PrunePostsParams{
+				FeedID:      feed.ID,
+				PublishedAt: pruneCutoff(limits),
+				Limit:       limit,
+			})
+		}
+		if err != nil {
+			return results, dbError("Error pruning posts of feed %v: %w", feed.Url, err)
+		}
+		if count > 0 {
+			results = append(results, pruneResult{feed: feed, posts: count})
+		}
+	}
+
+	return results, nil
+}
+
+// Returns the publish time before which posts are pruned, or the zero
+// time when posts are kept regardless of age
+func pruneCutoff(limits config.FeedRetention) time.Time {
+	if limits.MaxAge <= 0 {
+		return time.Time{}
+	}
+	return time.Now().UTC().Add(-time.Duration(limits.MaxAge))
+}
+
+// Returns the publish time before which fetched items are not saved:
+// they would be pruned again, and once a feed has max_posts posts that
+// includes everything older than its oldest kept post
+func saveCutoff(s *State, feedID uuid.UUID, feedUrl string) time.Time {
+	limits := s.config.Retention.For(feedUrl)
+	cutoff := pruneCutoff(limits)
+	if limits.MaxPosts <= 0 {
+		return cutoff
+	}
+
+	oldestKept, err := s.db.GetNthNewestPostDate(context.Background(), database.GetNthNewestPostDateParams{
+		FeedID: feedID,
+		Offset: int32(min(limits.MaxPosts-1, math.MaxInt32)),
+	})
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Error getting the oldest kept post of feed %v: %v\n", feedUrl, err)
+		}
+		return cutoff
+	}
+	if oldestKept.After(cutoff) {
+		return oldestKept
+	}
+	return cutoff
+}
+
+// Prunes posts from agg once per retention.prune_interval
+type pruneSchedule struct {
+	last time.Time
+}
+
+func (p *pruneSchedule) run(s *State) error {
+	retention := s.config.Retention
+	if !retention.Enabled() || time.Since(p.last) < time.Duration(retention.PruneInterval) {
+		return nil
+	}
+	p.last = time.Now()
+
+	results, err := prunePosts(context.Background(), s, false)
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		fmt.Printf("Pruned %d post(s) from %v\n", result.posts, result.feed.Name)
+	}
+	return nil
+}
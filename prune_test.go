@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/voylento/gator/internal/config"
+	"github.com/voylento/gator/internal/rss"
+)
+
+func TestScrapeAfterPruneDoesNotResavePrunedPosts(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		const feedUrl = "https://example.com/feed.xml"
+		item := func(title, pubDate string) rss.RSSItem {
+			return rss.RSSItem{
+				Title:       title,
+				Link:        "https://example.com/" + title,
+				Description: title + " description",
+				PubDate:     pubDate,
+			}
+		}
+		s := newTestState(t, backend, &fakeFetcher{feeds: map[string]*rss.RSSFeed{
+			feedUrl: newFakeFeed("Example",
+				item("newest", "Wed, 04 Sep 2024 10:00:00 +0000"),
+				item("middle", "Tue, 03 Sep 2024 10:00:00 +0000"),
+				item("oldest", "Mon, 02 Sep 2024 10:00:00 +0000"),
+			),
+		}})
+		addTestFeed(t, s, feedUrl)
+
+		if err := scrapeFeeds(s); err != nil {
+			t.Fatalf("scrapeFeeds: %v", err)
+		}
+		if got := postTitles(t, s); len(got) != 3 {
+			t.Fatalf("saved posts %q before retention was set, want all 3", got)
+		}
+
+		s.config.Retention.MaxPostsPerFeed = 2
+		mustRun(t, s, "prune")
+
+		// the feed still lists the pruned post
+		if err := scrapeFeeds(s); err != nil {
+			t.Fatalf("scrapeFeeds: %v", err)
+		}
+		want := []string{"middle", "newest"}
+		if got := postTitles(t, s); !slices.Equal(got, want) {
+			t.Errorf("posts after prune and scrape = %q, want %q", got, want)
+		}
+	})
+}
+
+func TestFeedSeturlMovesRetentionLimits(t *testing.T) {
+	const oldUrl, newUrl = "https://example.com/feed.xml", "https://example.com/rss"
+	s := newTestState(t, "memdb", &fakeFetcher{feeds: map[string]*rss.RSSFeed{
+		oldUrl: newFakeFeed("Example"),
+	}})
+	contents := fmt.Sprintf(`{"db_url": "sqlite:gator.db", "retention": {"feeds": {%q: {"max_posts": 10}}}}`, oldUrl)
+	if err := os.WriteFile(s.config.Path(), []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.config.ReadFile(""); err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	mustRun(t, s, "register", "alice")
+	mustRun(t, s, "addfeed", oldUrl)
+	mustRun(t, s, "feed", "seturl", oldUrl, newUrl)
+
+	if got := s.config.Retention.For(newUrl).MaxPosts; got != 10 {
+		t.Errorf("max posts after seturl = %d, want 10", got)
+	}
+	saved, err := config.LoadConfig(config.Options{Path: s.config.Path()})
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if _, ok := saved.Retention.Feeds[oldUrl]; ok {
+		t.Errorf("retention.feeds still has the old url: %v", saved.Retention.Feeds)
+	}
+	if got := saved.Retention.For(newUrl).MaxPosts; got != 10 {
+		t.Errorf("saved max posts after seturl = %d, want 10", got)
+	}
+}
+
+func TestStarredPostsAreNotPruned(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, backend string) {
+		// browse caches the listed posts for star in the temp directory
+		t.Setenv("TMPDIR", t.TempDir())
+
+		const feedUrl = "https://example.com/feed.xml"
+		newState := func() *State {
+			return newTestState(t, backend, &fakeFetcher{feeds: map[string]*rss.RSSFeed{
+				feedUrl: newFakeFeed("Example",
+					rss.RSSItem{Title: "Newest", Link: "https://example.com/3", Description: "three", PubDate: "2026-10-07T09:00:00Z"},
+					rss.RSSItem{Title: "Middle", Link: "https://example.com/2", Description: "two", PubDate: "2026-10-06T09:00:00Z"},
+					rss.RSSItem{Title: "Oldest", Link: "https://example.com/1", Description: "one", PubDate: "2026-10-05T09:00:00Z"},
+				),
+			}})
+		}
+		s := newState()
+		mustRun(t, s, "register", "alice")
+		mustRun(t, s, "addfeed", feedUrl)
+		mustRun(t, s, "browse", "10")
+
+		// post_id 2 is the oldest post
+		mustRun(t, s, "star", "2")
+		s.config.Retention.MaxPostsPerFeed = 1
+		mustRun(t, s, "prune")
+		if got, want := postTitles(t, s), []string{"Newest", "Oldest"}; !slices.Equal(got, want) {
+			t.Errorf("posts after prune = %q, want %q", got, want)
+		}
+
+		// the starred post survives a backup and restore
+		path := filepath.Join(t.TempDir(), "backup.json")
+		mustRun(t, s, "backup", path)
+		restored := newState()
+		mustRun(t, restored, "restore", path)
+		posts, err := restored.db.GetAllPosts(context.Background())
+		if err != nil {
+			t.Fatalf("GetAllPosts: %v", err)
+		}
+		for _, post := range posts {
+			if post.Starred != (post.Title == "Oldest") {
+				t.Errorf("restored post %v starred = %v", post.Title, post.Starred)
+			}
+		}
+
+		mustRun(t, s, "unstar", "2")
+		mustRun(t, s, "prune")
+		if got, want := postTitles(t, s), []string{"Newest"}; !slices.Equal(got, want) {
+			t.Errorf("posts after unstar and prune = %q, want %q", got, want)
+		}
+
+		err = runCommand(s, "star", "2")
+		if code := exitCode(err); code != exitNotFound {
+			t.Errorf("star of a pruned post exit code = %d (%v), want %d", code, err, exitNotFound)
+		}
+	})
+}
@@ -23,14 +23,16 @@ SELECT * FROM users;
 TRUNCATE TABLE users CASCADE;
 
 -- name: CreateFeed :one
-INSERT INTO feeds (id, created_at, updated_at, name, url, user_id)
+INSERT INTO feeds (id, created_at, updated_at, name, url, user_id, site_url, description)
 VALUES (
   $1,
   $2,
   $3,
   $4,
   $5,
-  $6
+  $6,
+  $7,
+  $8
 )
 RETURNING *;
 
@@ -48,6 +50,26 @@ WHERE user_id = $1;
 -- name: DeleteAllFeeds :exec
 TRUNCATE TABLE feeds;
 
+-- name: UpdateFeedName :one
+UPDATE feeds
+SET name = $2,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdateFeedUrl :one
+UPDATE feeds
+SET url = $2,
+    redirect_url = NULL,
+    redirect_count = 0,
+    retired_at = NULL,
+    updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteFeed :exec
+DELETE FROM feeds WHERE id = $1;
+
 -- name: CreateFeedFollow :many
 WITH inserted_feed_follow AS (
   INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id)
@@ -74,6 +96,7 @@ SELECT
   ff.user_id,
   ff.feed_id,
   f.name AS feed_name,
+  f.url AS feed_url,
   u.name AS user_name
 FROM feed_follows ff
 INNER JOIN feeds f ON ff.feed_id = f.id
@@ -95,6 +118,7 @@ WHERE id = $1;
 -- name: GetNextFeedToFetch :one
 SELECT id, created_at, updated_at, last_fetched_at, name, url, user_id
 FROM feeds
+WHERE retired_at IS NULL
 ORDER BY 
     -- Prioritize feeds that have never been fetched (NULL values first)
     CASE WHEN last_fetched_at IS NULL THEN 0 ELSE 1 END,
@@ -104,6 +128,34 @@ ORDER BY
     id
 LIMIT 1;
 
+-- name: RecordFeedRedirect :one
+-- Counts consecutive fetches that were permanently redirected to the same url
+UPDATE feeds
+SET redirect_count = CASE WHEN redirect_url = $2 THEN redirect_count + 1 ELSE 1 END,
+    redirect_url = $2
+WHERE id = $1
+RETURNING redirect_count;
+
+-- name: ClearFeedRedirect :exec
+UPDATE feeds
+SET redirect_url = NULL,
+    redirect_count = 0
+WHERE id = $1 AND redirect_url IS NOT NULL;
+
+-- name: MoveFeed :exec
+UPDATE feeds
+SET url = $2,
+    redirect_url = NULL,
+    redirect_count = 0,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: RetireFeed :exec
+UPDATE feeds
+SET retired_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1;
+
 -- name: CreatePost :one
 INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id)
 VALUES (
@@ -126,3 +178,80 @@ INNER JOIN feeds ON posts.feed_id = feeds.id
 WHERE feed_follows.user_id = $1
 ORDER BY posts.published_at DESC
 LIMIT $2;
+
+-- name: SetPostStarred :execrows
+UPDATE posts
+SET starred = $2,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: CountPostsToPrune :one
+-- Counts the unstarred posts of a feed published before $2 or beyond its
+-- newest $3 unstarred posts
+SELECT COUNT(*) FROM posts
+WHERE feed_id = $1
+  AND NOT starred
+  AND (published_at < $2 OR id NOT IN (
+    SELECT kept.id FROM posts AS kept
+    WHERE kept.feed_id = $1 AND NOT kept.starred
+    ORDER BY kept.published_at DESC, kept.created_at DESC
+    LIMIT $3
+  ));
+
+-- name: PrunePosts :execrows
+-- Deletes the unstarred posts of a feed published before $2 or beyond its
+-- newest $3 unstarred posts
+DELETE FROM posts
+WHERE feed_id = $1
+  AND NOT starred
+  AND (published_at < $2 OR id NOT IN (
+    SELECT kept.id FROM posts AS kept
+    WHERE kept.feed_id = $1 AND NOT kept.starred
+    ORDER BY kept.published_at DESC, kept.created_at DESC
+    LIMIT $3
+  ));
+
+-- name: GetNthNewestPostDate :one
+-- Returns the publish time of a feed's unstarred post at offset $2 in
+-- prune order
+SELECT published_at FROM posts
+WHERE feed_id = $1 AND NOT starred
+ORDER BY published_at DESC, created_at DESC
+LIMIT 1 OFFSET $2;
+
+-- name: GetAllPosts :many
+SELECT * FROM posts
+ORDER BY feed_id, published_at;
+
+-- name: RestoreFeed :exec
+-- Inserts a feed from a backup with all of its fetch state
+INSERT INTO feeds (id, created_at, updated_at, name, url, user_id, last_fetched_at, redirect_url, redirect_count, retired_at, site_url, description)
+VALUES (
+  $1,
+  $2,
+  $3,
+  $4,
+  $5,
+  $6,
+  $7,
+  $8,
+  $9,
+  $10,
+  $11,
+  $12
+);
+
+-- name: RestorePost :exec
+-- Inserts a post from a backup, keeping its id and timestamps
+INSERT INTO posts (id, created_at, updated_at, title, url, description, published_at, feed_id, starred)
+VALUES (
+  $1,
+  $2,
+  $3,
+  $4,
+  $5,
+  $6,
+  $7,
+  $8,
+  $9
+);
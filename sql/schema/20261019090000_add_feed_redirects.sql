@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE feeds
+  ADD COLUMN redirect_url   TEXT,
+  ADD COLUMN redirect_count INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN retired_at     TIMESTAMP;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE feeds
+  DROP COLUMN redirect_url,
+  DROP COLUMN redirect_count,
+  DROP COLUMN retired_at;
+-- +goose StatementEnd
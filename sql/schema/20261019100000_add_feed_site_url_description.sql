@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE feeds
+  ADD COLUMN site_url    TEXT NOT NULL DEFAULT '',
+  ADD COLUMN description TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE feeds
+  DROP COLUMN site_url,
+  DROP COLUMN description;
+-- +goose StatementEnd
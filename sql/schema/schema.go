@@ -0,0 +1,8 @@
+// Package schema embeds the goose migrations so the gator binary can
+// migrate its database without the sql directory on disk.
+package schema
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,52 @@
+-- +goose Up
+-- The SQLite schema starts at the current Postgres schema. ids are stored
+-- as text and timestamps as UTC text, which sorts chronologically.
+CREATE TABLE users (
+  id          TEXT PRIMARY KEY,
+  created_at  TIMESTAMP NOT NULL,
+  updated_at  TIMESTAMP NOT NULL,
+  name        TEXT UNIQUE NOT NULL
+);
+
+CREATE TABLE feeds (
+  id              TEXT PRIMARY KEY,
+  created_at      TIMESTAMP NOT NULL,
+  updated_at      TIMESTAMP NOT NULL,
+  name            TEXT NOT NULL,
+  url             TEXT UNIQUE NOT NULL,
+  user_id         TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  last_fetched_at TIMESTAMP,
+  redirect_url    TEXT,
+  redirect_count  INTEGER NOT NULL DEFAULT 0,
+  retired_at      TIMESTAMP,
+  site_url        TEXT NOT NULL DEFAULT '',
+  description     TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE feed_follows (
+  id          TEXT PRIMARY KEY,
+  created_at  TIMESTAMP NOT NULL,
+  updated_at  TIMESTAMP NOT NULL,
+  user_id     TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  feed_id     TEXT NOT NULL REFERENCES feeds(id) ON DELETE CASCADE,
+  CONSTRAINT unique_user_feed UNIQUE(user_id, feed_id)
+);
+
+CREATE TABLE posts (
+  id            TEXT PRIMARY KEY,
+  created_at    TIMESTAMP NOT NULL,
+  updated_at    TIMESTAMP NOT NULL,
+  title         TEXT NOT NULL,
+  url           TEXT UNIQUE NOT NULL,
+  description   TEXT NOT NULL,
+  published_at  TIMESTAMP NOT NULL,
+  feed_id       TEXT NOT NULL REFERENCES feeds(id) ON DELETE CASCADE
+);
+
+CREATE INDEX posts_feed_id ON posts(feed_id);
+
+-- +goose Down
+DROP TABLE posts;
+DROP TABLE feed_follows;
+DROP TABLE feeds;
+DROP TABLE users;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- Starred posts are never pruned
+ALTER TABLE posts ADD COLUMN starred BOOLEAN NOT NULL DEFAULT false;
+
+-- +goose Down
+ALTER TABLE posts DROP COLUMN starred;
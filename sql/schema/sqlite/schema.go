@@ -0,0 +1,9 @@
+// Package sqlite embeds the goose migrations for the SQLite backend. They
+// mirror the Postgres migrations in the parent directory; a schema change
+// needs a migration in both.
+package sqlite
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
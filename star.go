@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/voylento/gator/internal/database"
+)
+
+// Stars or unstars a post listed by the last browse command. Starred posts
+// are never pruned and do not count toward max_posts.
+func handleStar(s *State, cmd Command) error {
+	post, err := cachedPost(cmd)
+	if err != nil {
+		return err
+	}
+
+	starred := cmd.name == "star"
+	updated, err := s.db.SetPostStarred(context.Background(), database.SetPostStarredParams{
+		ID:      post.ID,
+		Starred: starred,
+	})
+	if err != nil {
+		return dbError("Error updating post %v: %w", post.Url, err)
+	}
+	if updated == 0 {
+		return notFoundError("Post %v no longer exists. Run 'gator browse' to list the current posts.", post.Url)
+	}
+
+	if starred {
+		fmt.Printf("Starred %v\n", post.Title)
+	} else {
+		fmt.Printf("Unstarred %v\n", post.Title)
+	}
+	return nil
+}